@@ -2,25 +2,96 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/romanthekat/gemini-tools/internal/bookmarks"
+	"github.com/romanthekat/gemini-tools/internal/config"
+	"github.com/romanthekat/gemini-tools/internal/download"
+	"github.com/romanthekat/gemini-tools/internal/feeds"
 	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/history"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+	"github.com/romanthekat/gemini-tools/internal/pager"
+	"github.com/romanthekat/gemini-tools/internal/render"
+	"github.com/romanthekat/gemini-tools/internal/term"
+	"github.com/romanthekat/gemini-tools/internal/tui"
 )
 
+var client = gemini.NewClient()
+
+// renderer wraps gemtext pages, width is set from flags in main
+var renderer = render.New(0)
+
+// viewer pages long documents, nil prints them at once
+var viewer *pager.Pager
+
+// visits is global history shared by all tabs, nil if it could not be loaded
+var visits *history.Log
+
+// saved are bookmarks shared by all tabs, nil if they could not be loaded
+var saved *bookmarks.Store
+
+// subscriptions are followed gemlogs, nil if they could not be loaded
+var subscriptions *feeds.Subscriptions
+
+// downloadDir keeps saved responses, handlers open non-text ones by media type
+var (
+	downloadDir = download.DefaultDir()
+	handlers    = download.Handlers{}
+)
+
+// cache keeps visited pages and serves them while fresh or when capsule is unreachable, nil disables it.
+// In offline and hybrid modes it is the crawler DB
+var cache *pagedb.Cache
+
+// modes of the browser: online requests capsules, offline reads crawler DB only and queues missing pages,
+// hybrid reads crawler DB first and stores pages requested on misses into it
 const (
-	LinkPrefix    = "=>"
-	Header1Prefix = "#"
-	Header2Prefix = "##"
-	Header3Prefix = "###"
+	modeOnline  = "online"
+	modeOffline = "offline"
+	modeHybrid  = "hybrid"
 )
 
+var mode = modeOnline
+
+const defaultHome = "gemini://geminiprotocol.net:1965/"
+
+// home is opened by g, bookmarks.StartPage shows bookmarks instead of a capsule
+var home = defaultHome
+
+// prompt is shown before every command
+var prompt = "🔴➡ "
+
 type State struct {
 	Links   []string
-	History []string
+	History *history.Stack
+	// Travel is offset in History to move to once the requested page is shown, 0 for a new page
+	Travel int
+	// Reload requests the next page from capsule even if it is cached
+	Reload bool
+	// Title is heading of the shown page, empty if it has none
+	Title string
+	// last requested URL, kept even if the request failed
+	Last *url.URL
+	// Response is the last shown one, its body may be already read
+	Response *gemini.Response
+	// Next is a command chosen in the pager, used instead of reading user input
+	Next string
+	// BookmarksTag is tag of the last shown bookmarks page, "bm del N" numbers refer to it
+	BookmarksTag string
 }
 
 func (s *State) clearLinks() {
@@ -28,24 +99,175 @@ func (s *State) clearLinks() {
 }
 
 func NewState() *State {
-	return &State{make([]string, 0, 100), make([]string, 0, 100)}
+	return &State{Links: make([]string, 0, 100), History: history.NewStack()}
+}
+
+// Tabs are independent browsing sessions, each with its own links and history
+type Tabs struct {
+	States []*State
+	// Active is index of the current tab
+	Active int
+}
+
+func NewTabs() *Tabs {
+	return &Tabs{States: []*State{NewState()}}
+}
+
+func (t *Tabs) Current() *State {
+	return t.States[t.Active]
+}
+
+// Open adds an empty tab after the last one and makes it current
+func (t *Tabs) Open() *State {
+	state := NewState()
+	t.States = append(t.States, state)
+	t.Active = len(t.States) - 1
+	return state
+}
+
+// Switch makes tab with number, starting from 1, current
+func (t *Tabs) Switch(number int) error {
+	if number < 1 || number > len(t.States) {
+		return fmt.Errorf("no tab with number %d", number)
+	}
+	t.Active = number - 1
+	return nil
+}
+
+// Close removes tab with number, starting from 1. The last remaining tab cannot be closed
+func (t *Tabs) Close(number int) error {
+	if number < 1 || number > len(t.States) {
+		return fmt.Errorf("no tab with number %d", number)
+	}
+	if len(t.States) == 1 {
+		return fmt.Errorf("cannot close the last tab")
+	}
+
+	t.States = append(t.States[:number-1], t.States[number:]...)
+	if t.Active >= number-1 && t.Active > 0 {
+		t.Active--
+	}
+	return nil
 }
 
 func main() {
+	cfg, cfgErr := config.LoadDefault()
+	if cfg.Client.Downloads != "" {
+		downloadDir = cfg.Client.Downloads
+	}
+	for mediaType, command := range cfg.Handlers {
+		handlers[mediaType] = command
+	}
+	prompt = cfg.Client.Prompt
+	cacheDir := cfg.Client.Cache
+	if cacheDir == "" {
+		// cache is disabled if there is no user cache dir
+		cacheDir, _ = pagedb.DefaultCacheDir()
+	}
+
+	maxSizeMB := flag.Int("max-mb", cfg.Client.MaxMB, "maximum response body size in MB, 0 means unlimited")
+	width := flag.Int("width", cfg.Client.Width, "wrap pages to this many columns, 0 means terminal width")
+	usePager := flag.Bool("pager", cfg.Client.Pager, "show pages longer than the terminal a screen at a time")
+	useTUI := flag.Bool("tui", cfg.Client.TUI, "full-screen browser with address bar and selectable links")
+	flag.StringVar(&home, "home", cfg.Client.Home, "page opened by g and at start, \""+bookmarks.StartPage+"\" for bookmarks")
+	flag.StringVar(&downloadDir, "downloads", downloadDir, "directory for saved responses")
+	flag.StringVar(&cacheDir, "cache", cacheDir, "directory keeping visited pages, crawler db can be used; empty disables cache")
+	cacheTTL := flag.Duration("cache-ttl", cfg.Client.CacheTTL, "cached pages younger than this are shown without requests")
+	flag.StringVar(&mode, "mode", cfg.Client.Mode, "online, offline to read crawler db only or hybrid to read it first and request misses")
+	dbDir := flag.String("db", cfg.Client.DB, "crawler database root directory, used in offline and hybrid modes")
+	queuePath := flag.String("queue", cfg.Client.Queue, "crawler queue file, pages missing in offline mode are appended to it")
+	flag.Var(handlers, "handler", "open media TYPE with COMMAND, given as TYPE=COMMAND where TYPE may be like image/*, repeatable")
+	flag.Parse()
+
+	if cfgErr != nil {
+		fmt.Println("\033[31mconfig ignored:", cfgErr, "\033[0m") //red
+	}
+	if theme, err := cfg.Colors.Theme(); err == nil {
+		renderer.Theme = theme
+	} else {
+		fmt.Println("\033[31mcolor theme ignored:", err, "\033[0m") //red
+	}
+
+	client.Timeouts = cfg.Timeouts
+	switch mode {
+	case modeOnline:
+		if cacheDir != "" {
+			cache = pagedb.NewCache(cacheDir, *cacheTTL)
+		}
+	case modeOffline, modeHybrid:
+		cache = pagedb.NewCache(*dbDir, pagedb.Forever)
+		if mode == modeOffline {
+			cache.Offline = true
+			cache.Queue = *queuePath
+		}
+	default:
+		fmt.Println("unknown mode:", mode)
+		os.Exit(-1)
+	}
+	client.MaxBodySize = int64(*maxSizeMB) << 20
+	renderer.Width = *width
+	if renderer.Width <= 0 {
+		renderer.Width = render.TerminalWidth(int(os.Stdout.Fd()))
+	}
+
 	reader := bufio.NewReader(os.Stdin)
+	if _, height, err := term.Size(int(os.Stdout.Fd())); err == nil && *usePager {
+		viewer = pager.New(reader, os.Stdout, height)
+	}
 
-	state := NewState()
+	tabs := NewTabs()
+
+	if err := loadKnownHosts(); err != nil {
+		fmt.Println("\033[31mcertificate pinning disabled:", err, "\033[0m") //red
+	}
+	if err := loadIdentities(); err != nil {
+		fmt.Println("\033[31mclient certificates disabled:", err, "\033[0m") //red
+	}
+	if err := loadHistory(); err != nil {
+		fmt.Println("\033[31mglobal history disabled:", err, "\033[0m") //red
+	}
+	if err := loadBookmarks(); err != nil {
+		fmt.Println("\033[31mbookmarks disabled:", err, "\033[0m") //red
+	}
+	if err := loadSubscriptions(); err != nil {
+		fmt.Println("\033[31msubscriptions disabled:", err, "\033[0m") //red
+	}
+
+	if *useTUI {
+		if err := runTUI(reader, flag.Arg(0)); err != nil {
+			fmt.Println("full-screen mode failed:", err)
+			os.Exit(-1)
+		}
+		return
+	}
 
 	printHelp()
+	if home != defaultHome {
+		tabs.Current().Next = "g"
+	} else if mode == modeOffline {
+		tabs.Current().Next = "t"
+	}
 
 	for {
-		input, err := getUserInput(reader)
-		if err != nil {
-			fmt.Println("user input read failed:", err)
-			os.Exit(-1)
+		input := tabs.Current().Next
+		tabs.Current().Next = ""
+		if input == "" {
+			var err error
+			input, err = getUserInput(reader)
+			if err != nil {
+				fmt.Println("user input read failed:", err)
+				os.Exit(-1)
+			}
 		}
 
-		link, doNothing, err := processUserInput(input, state)
+		var link *url.URL
+		var doNothing bool
+		var err error
+		if fields := strings.Fields(input); len(fields) > 0 && fields[0] == "tab" {
+			link, doNothing, err = processTabCommand(fields[1:], tabs)
+		} else {
+			link, doNothing, err = processUserInput(input, tabs.Current())
+		}
 		if err != nil {
 			fmt.Println("error processing user input:", err)
 			continue
@@ -54,33 +276,623 @@ func main() {
 			continue
 		}
 
-		response, err := gemini.DoRequest(link)
+		if err := navigate(reader, tabs.Current(), link); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// runTUI browses in full-screen mode starting from linkRaw, or from help if it is empty
+func runTUI(reader *bufio.Reader, linkRaw string) error {
+	var start *url.URL
+	if linkRaw != "" {
+		var err error
+		if start, err = gemini.GetFullGeminiLink(linkRaw); err != nil {
+			return err
+		}
+	}
+
+	width, height, err := term.Size(int(os.Stdout.Fd()))
+	if err != nil {
+		return err
+	}
+	restore, err := term.Raw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	app := tui.New(client, renderer, reader, os.Stdout, width, height)
+	app.Size = func() (int, int, error) { return term.Size(int(os.Stdout.Fd())) }
+	app.History = visits
+	app.Bookmarks = saved
+	app.Downloads = downloadDir
+	app.Cache = cache
+	if home != defaultHome {
+		app.Home = home
+	}
+	return app.Run(start)
+}
+
+// navigate requests link and shows the response, Ctrl-C cancels it instead of quitting the client.
+// Fresh cached pages are shown without requests, stale ones if capsule is unreachable
+func navigate(reader *bufio.Reader, state *State, link *url.URL) error {
+	reload := state.Reload
+	state.Reload = false
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	response, cached, err := cache.Fetch(ctx, client, link, reload)
+	var mismatch *gemini.CertMismatchError
+	if errors.As(err, &mismatch) && confirmTrust(reader, mismatch) {
+		response, cached, err = cache.Fetch(ctx, client, link, true)
+	}
+	// capsules may ask for input several times in a row
+	for err == nil && response.Status == gemini.StatusInput {
+		// cancelled or invalid input is not a capsule failure, so there is no stale copy to show
+		query, inputErr := requestInput(reader, link, response)
+		if inputErr != nil {
+			return inputErr
+		}
+		link = query
+		response, cached, err = cache.Fetch(ctx, client, link, true)
+	}
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	switch {
+	case cached == nil:
+	case cached.Stale:
+		fmt.Println("\033[31m", cached.Reason, "\033[0m")                                                                            //red
+		fmt.Println("\033[33mcapsule unreachable, showing stale copy from", cached.Fetched.Local().Format(time.DateTime), "\033[0m") //orange
+	case mode == modeOnline:
+		fmt.Println("\033[33mcached", cached.Fetched.Local().Format(time.DateTime), "(r reloads)\033[0m") //orange
+	}
+	return showResponse(reader, state, link, response)
+}
+
+// showResponse shows response to link, non-text ones are offered for download
+func showResponse(reader *bufio.Reader, state *State, link *url.URL, response *gemini.Response) error {
+	state.Response = response
+	if len(response.Redirects) > 0 {
+		// relative links and history refer to the page we landed on
+		link = response.URL
+		fmt.Println("\033[33mredirected to", link, "\033[0m") //orange
+	}
+
+	if response.Status == gemini.StatusSuccess {
+		if mediaType, err := response.MediaType(); err == nil && !mediaType.IsText() {
+			state.Last = link
+			return offerDownload(reader, link, mediaType, response)
+		}
+	}
+
+	err := processResponse(state, link, response)
+	if err != nil {
+		return fmt.Errorf("error processing response: %w", err)
+	}
+	return nil
+}
+
+// offerDownload asks whether non-text response should be saved or opened with its handler
+func offerDownload(reader *bufio.Reader, link *url.URL, mediaType gemini.MediaType, response *gemini.Response) error {
+	if response.BodyReader != nil {
+		defer response.BodyReader.Close()
+	}
+
+	name := download.FileName(link, mediaType)
+	command, canOpen := handlers.Lookup(mediaType.Type)
+	fmt.Printf("\033[33m%s is %s\033[0m\n", name, mediaType.Type) //orange
+	if canOpen {
+		fmt.Printf("[s]ave to %s, [o]pen with %s, anything else to skip: ", downloadDir, command)
+	} else {
+		fmt.Printf("[s]ave to %s, anything else to skip: ", downloadDir)
+	}
+
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("answer read failed: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s":
+		path, err := download.Save(downloadDir, name, responseBody(response))
+		if err != nil {
+			return fmt.Errorf("saving failed: %w", err)
+		}
+		fmt.Println("saved to", path)
+
+	case "o":
+		if !canOpen {
+			return nil
+		}
+		path, err := download.Save(filepath.Join(os.TempDir(), "gemini-tools"), name, responseBody(response))
 		if err != nil {
-			fmt.Println("request failed:", err)
-			continue
+			return fmt.Errorf("saving failed: %w", err)
+		}
+		return download.Open(command, path)
+	}
+	return nil
+}
+
+// saveLink requests link and writes its body to download dir as is
+func saveLink(link *url.URL) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var response *gemini.Response
+	var err error
+	if cache != nil && cache.Offline {
+		response, err = fetch(link)
+	} else {
+		response, err = client.Stream(ctx, link)
+	}
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if response.BodyReader != nil {
+		defer response.BodyReader.Close()
+	}
+	if response.Status != gemini.StatusSuccess {
+		if err := response.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("unexpected response: %d %s", response.Code, response.Meta)
+	}
+
+	mediaType, err := response.MediaType()
+	if err != nil {
+		return err
+	}
+	if response.URL != nil {
+		link = response.URL
+	}
+
+	path, err := download.Save(downloadDir, download.FileName(link, mediaType), responseBody(response))
+	if err != nil {
+		return fmt.Errorf("saving failed: %w", err)
+	}
+	fmt.Println("saved to", path)
+	return nil
+}
+
+// fetch requests link in online and hybrid modes, in offline mode it is read from DB only
+func fetch(link *url.URL) (*gemini.Response, error) {
+	if cache == nil || !cache.Offline {
+		return client.DoRequest(link)
+	}
+
+	response, _, _, err := cache.Get(link)
+	if err != nil {
+		return nil, cache.Missing(link)
+	}
+	return response, nil
+}
+
+// responseBody reads streamed body if there is one, buffered body otherwise
+func responseBody(response *gemini.Response) io.Reader {
+	if response.BodyReader != nil {
+		return response.BodyReader
+	}
+	return bytes.NewReader(response.Body)
+}
+
+func loadKnownHosts() error {
+	path, err := gemini.DefaultKnownHostsPath()
+	if err != nil {
+		return err
+	}
+
+	knownHosts, err := gemini.NewFileKnownHosts(path)
+	if err != nil {
+		return err
+	}
+
+	client.KnownHosts = knownHosts
+	return nil
+}
+
+func loadIdentities() error {
+	dir, err := gemini.DefaultIdentitiesDir()
+	if err != nil {
+		return err
+	}
+
+	identities, err := gemini.NewIdentityStore(dir)
+	if err != nil {
+		return err
+	}
+
+	client.Identities = identities
+	return nil
+}
+
+func loadHistory() error {
+	path, err := history.DefaultLogPath()
+	if err != nil {
+		return err
+	}
+
+	log, err := history.NewLog(path)
+	if err != nil {
+		return err
+	}
+
+	visits = log
+	return nil
+}
+
+func loadBookmarks() error {
+	path, err := bookmarks.DefaultPath()
+	if err != nil {
+		return err
+	}
+
+	store, err := bookmarks.NewStore(path)
+	if err != nil {
+		return err
+	}
+
+	saved = store
+	return nil
+}
+
+func loadSubscriptions() error {
+	dir, err := feeds.DefaultDir()
+	if err != nil {
+		return err
+	}
+
+	subs, err := feeds.NewSubscriptions(dir)
+	if err != nil {
+		return err
+	}
+
+	subscriptions = subs
+	return nil
+}
+
+// processSubscriptionCommand lists subscriptions, subscribes to a feed or current page, or unsubscribes
+func processSubscriptionCommand(args []string, state *State) error {
+	if subscriptions == nil {
+		return fmt.Errorf("subscriptions are not available")
+	}
+
+	if len(args) == 0 {
+		list := subscriptions.List()
+		if len(list) == 0 {
+			fmt.Println("No subscriptions yet")
+			return nil
+		}
+		for i, sub := range list {
+			fmt.Printf("[%d] \u001B[34m%s\033[0m %s\n", i+1, sub.URL, sub.Title)
+		}
+		return nil
+	}
+
+	switch command := args[0]; command {
+	case "add":
+		if len(args) > 2 {
+			return fmt.Errorf("usage: sub add [URL]")
+		}
+		link := state.Last
+		if len(args) == 2 {
+			var err error
+			if link, err = targetLink(args[1], state); err != nil {
+				return err
+			}
+		}
+		if link == nil {
+			return fmt.Errorf("no page opened yet")
 		}
 
-		err = processResponse(state, link, response)
+		feed, err := feeds.FetchFeed(fetch, link)
 		if err != nil {
-			fmt.Println("error processing response:", err)
-			continue
+			return fmt.Errorf("%s is not a feed: %w", link, err)
 		}
+		if err := subscriptions.Subscribe(feed); err != nil {
+			return err
+		}
+		fmt.Printf("subscribed to %s, %d posts so far\n", feed.Title, len(feed.Entries))
+		return nil
+
+	case "del":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: sub del N")
+		}
+		number, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("not a subscription number: %s", args[1])
+		}
+		removed, err := subscriptions.Unsubscribe(number)
+		if err != nil {
+			return err
+		}
+		fmt.Println("unsubscribed from", removed.URL)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subscription command: %s", command)
 	}
 }
 
+// showNewPosts refreshes all subscriptions and shows posts not seen before as a page
+func showNewPosts(state *State) error {
+	if subscriptions == nil {
+		return fmt.Errorf("subscriptions are not available")
+	}
+
+	fmt.Println("refreshing", len(subscriptions.List()), "subscriptions")
+	unseen, errs := subscriptions.Refresh(fetch)
+	for _, err := range errs {
+		fmt.Println("\033[31m", err, "\033[0m") //red
+	}
+
+	if err := showDocument(state, feeds.Page(unseen), nil); err != nil {
+		return err
+	}
+	return subscriptions.MarkSeen(unseen)
+}
+
+// processBookmarkCommand shows bookmarks page, optionally only with a tag, adds current page or deletes a bookmark
+func processBookmarkCommand(args []string, state *State) error {
+	if saved == nil {
+		return fmt.Errorf("bookmarks are not available")
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], bookmarks.TagPrefix) {
+		tag := ""
+		if len(args) > 0 {
+			tag = args[0]
+		}
+		state.BookmarksTag = tag
+		return showDocument(state, saved.Page(tag), nil)
+	}
+
+	switch command := args[0]; command {
+	case "add":
+		if state.Last == nil {
+			return fmt.Errorf("no page opened yet")
+		}
+
+		var titleWords, tags []string
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, bookmarks.TagPrefix) {
+				tags = append(tags, arg)
+			} else {
+				titleWords = append(titleWords, arg)
+			}
+		}
+		title := strings.Join(titleWords, " ")
+		if title == "" {
+			title = state.Title
+		}
+
+		if err := saved.Add(state.Last.String(), title, tags, time.Now()); err != nil {
+			return err
+		}
+		fmt.Println("bookmarked", state.Last)
+		return nil
+
+	case "del":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: bm del N")
+		}
+		number, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("not a bookmark number: %s", args[1])
+		}
+		if err := saved.Delete(state.BookmarksTag, number); err != nil {
+			return err
+		}
+		fmt.Println("deleted bookmark", number)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown bookmark command: %s", command)
+	}
+}
+
+// processIdentityCommand lists, creates, activates or deletes client certificates
+func processIdentityCommand(args []string, state *State) error {
+	identities := client.Identities
+	if identities == nil {
+		return fmt.Errorf("client certificates are not available")
+	}
+
+	if len(args) == 0 {
+		list := identities.List()
+		if len(list) == 0 {
+			fmt.Println("No client certificates yet")
+			return nil
+		}
+		for _, identity := range list {
+			fmt.Printf("\u001B[34m%s\033[0m %s\n", identity.Name, strings.Join(identity.Prefixes, " "))
+		}
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: id [new|use|del NAME]")
+	}
+	command, name := args[0], args[1]
+
+	switch command {
+	case "new":
+		if _, err := identities.Generate(name, identityValidity); err != nil {
+			return err
+		}
+		fmt.Println("created client certificate", name)
+		if state.Last == nil {
+			return nil
+		}
+		return activateIdentity(identities, name, state.Last)
+
+	case "use":
+		if state.Last == nil {
+			return fmt.Errorf("no capsule opened yet")
+		}
+		return activateIdentity(identities, name, state.Last)
+
+	case "del":
+		if err := identities.Delete(name); err != nil {
+			return err
+		}
+		fmt.Println("deleted client certificate", name)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown identity command: %s", command)
+	}
+}
+
+// processTabCommand lists, opens, switches or closes tabs. Link is returned when a new tab should load it
+func processTabCommand(args []string, tabs *Tabs) (*url.URL, bool, error) {
+	if len(args) == 0 {
+		for i, state := range tabs.States {
+			marker := " "
+			if i == tabs.Active {
+				marker = "*"
+			}
+			fmt.Printf("[%d]%s \u001B[34m%s\033[0m\n", i+1, marker, tabTitle(state))
+		}
+		return nil, true, nil
+	}
+
+	switch command := args[0]; command {
+	case "new":
+		if len(args) > 2 {
+			return nil, false, fmt.Errorf("usage: tab new [NUMBER|URL]")
+		}
+		if len(args) == 1 {
+			tabs.Open()
+			fmt.Println("opened tab", len(tabs.States))
+			return nil, true, nil
+		}
+
+		link, err := targetLink(args[1], tabs.Current())
+		if err != nil {
+			return nil, false, err
+		}
+		tabs.Open()
+		fmt.Printf("opened tab %d > %s\n", len(tabs.States), link)
+		return link, false, nil
+
+	case "use", "del":
+		number := tabs.Active + 1
+		if len(args) == 2 {
+			var err error
+			if number, err = strconv.Atoi(args[1]); err != nil {
+				return nil, false, fmt.Errorf("not a tab number: %s", args[1])
+			}
+		} else if len(args) > 2 || command == "use" {
+			return nil, false, fmt.Errorf("usage: tab use NUMBER, tab del [NUMBER]")
+		}
+
+		if command == "use" {
+			if err := tabs.Switch(number); err != nil {
+				return nil, false, err
+			}
+		} else if err := tabs.Close(number); err != nil {
+			return nil, false, err
+		}
+		fmt.Printf("tab %d: %s\n", tabs.Active+1, tabTitle(tabs.Current()))
+		return nil, true, nil
+
+	default:
+		return nil, false, fmt.Errorf("unknown tab command: %s", command)
+	}
+}
+
+// targetLink resolves a link number from state or a URL typed by user
+func targetLink(target string, state *State) (*url.URL, error) {
+	linkRaw := target
+	if index, err := strconv.Atoi(target); err == nil {
+		if index < 1 || index > len(state.Links) {
+			return nil, fmt.Errorf("no link with number %d", index)
+		}
+		linkRaw = state.Links[index-1]
+	} else if !strings.HasPrefix(linkRaw, gemini.Protocol) {
+		linkRaw = gemini.Protocol + linkRaw
+	}
+	return gemini.GetFullGeminiLink(linkRaw)
+}
+
+func tabTitle(state *State) string {
+	if state.Last == nil {
+		return "(empty)"
+	}
+	return state.Last.String()
+}
+
+const identityValidity = 5 * 365 * 24 * time.Hour
+
+// activateIdentity scopes identity to the whole capsule of link
+func activateIdentity(identities *gemini.IdentityStore, name string, link *url.URL) error {
+	capsule := &url.URL{Scheme: link.Scheme, Host: link.Host, Path: "/"}
+	if err := identities.Activate(name, capsule); err != nil {
+		return err
+	}
+	fmt.Printf("using client certificate %s for %s, reload the page to present it\n", name, capsule)
+	return nil
+}
+
+// confirmTrust asks whether a changed certificate should replace the pinned one
+func confirmTrust(reader *bufio.Reader, mismatch *gemini.CertMismatchError) bool {
+	fmt.Printf("\033[31mWARNING: certificate of %s has changed!\033[0m\n", mismatch.Addr) //red
+	fmt.Printf("known:     %s (expires %s)\n", mismatch.Known.Fingerprint, mismatch.Known.Expires.Format(time.DateOnly))
+	fmt.Printf("presented: %s (expires %s)\n", mismatch.Presented.Fingerprint, mismatch.Presented.Expires.Format(time.DateOnly))
+	fmt.Print("trust the new certificate? [y/N] ")
+
+	answer, err := reader.ReadString('\n')
+	if err != nil || strings.ToLower(strings.TrimSpace(answer)) != "y" {
+		return false
+	}
+
+	if err := client.KnownHosts.Trust(mismatch.Addr, mismatch.Presented); err != nil {
+		fmt.Println("storing certificate failed:", err)
+		return false
+	}
+	return true
+}
+
 func printHelp() {
 	fmt.Println("gemini://url\topen url")
 	fmt.Println("number\t\topen link from current page by number")
 	fmt.Println("b\t\tgo back")
+	fmt.Println("f\t\tgo forward")
+	fmt.Println("r\t\treload current page, skipping cache")
 	fmt.Println("q\t\tquit")
 	fmt.Println("h\t\tprint this summary")
-	fmt.Println("\ng\t\topen Project Gemini homepage")
+	fmt.Println("\ng\t\topen home page, client.home in config or --home, bookmarks page if it is \"bookmarks\"")
 	fmt.Println("l\t\tlinks from current page and history")
+	fmt.Println("t\t\tshow top sites in cache or crawler DB")
+	fmt.Println("i\t\tshow status, connection and certificate of current page")
+	fmt.Println("save [N|URL]\tsave current page, link number or url to downloads")
+	fmt.Println("\nbm [#TAG]\tshow bookmarks, only tagged ones if TAG is set")
+	fmt.Println("bm add [TITLE] [#TAG...]\tbookmark current page")
+	fmt.Println("bm del N\tdelete bookmark")
+	fmt.Println("\nsub\t\tlist subscriptions")
+	fmt.Println("sub add [N|URL]\tsubscribe to current page, link number or url")
+	fmt.Println("sub del N\tunsubscribe")
+	fmt.Println("feed\t\trefresh subscriptions and show new posts")
+	fmt.Println("hist N\t\tgo to history entry")
+	fmt.Println("log [TEXT]\tsearch global history by url or title, results become links")
+	fmt.Println("\ntab\t\tlist tabs")
+	fmt.Println("tab new [N|URL]\topen link number or url in a new tab")
+	fmt.Println("tab use N\tswitch to tab")
+	fmt.Println("tab del [N]\tclose tab, current one by default")
+	fmt.Println("\nid\t\tlist client certificates")
+	fmt.Println("id new NAME\tcreate client certificate and use it for current capsule")
+	fmt.Println("id use NAME\tuse client certificate for current capsule")
+	fmt.Println("id del NAME\tdelete client certificate")
 	fmt.Println()
 }
 
 func getUserInput(reader *bufio.Reader) (string, error) {
-	fmt.Print("🔴➡ ")
+	fmt.Print(prompt)
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		return "", err
@@ -91,6 +903,65 @@ func getUserInput(reader *bufio.Reader) (string, error) {
 
 func processUserInput(input string, state *State) (*url.URL, bool, error) {
 	linkRaw := ""
+	state.Travel = 0
+
+	if fields := strings.Fields(input); len(fields) > 0 {
+		switch fields[0] {
+		case "id":
+			if err := processIdentityCommand(fields[1:], state); err != nil {
+				return nil, false, err
+			}
+			return nil, true, nil
+
+		case "bm":
+			if err := processBookmarkCommand(fields[1:], state); err != nil {
+				return nil, false, err
+			}
+			return nil, true, nil
+
+		case "sub":
+			if err := processSubscriptionCommand(fields[1:], state); err != nil {
+				return nil, false, err
+			}
+			return nil, true, nil
+
+		case "save":
+			if len(fields) > 2 {
+				return nil, false, fmt.Errorf("usage: save [N|URL]")
+			}
+			link := state.Last
+			if len(fields) == 2 {
+				var err error
+				if link, err = targetLink(fields[1], state); err != nil {
+					return nil, false, err
+				}
+			}
+			if link == nil {
+				return nil, false, fmt.Errorf("no page opened yet")
+			}
+			return nil, true, saveLink(link)
+
+		case "feed":
+			if err := showNewPosts(state); err != nil {
+				return nil, false, err
+			}
+			return nil, true, nil
+
+		case "log":
+			showVisits(state, strings.TrimSpace(strings.TrimPrefix(input, "log")))
+			return nil, true, nil
+
+		case "hist":
+			if len(fields) != 2 {
+				return nil, false, fmt.Errorf("usage: hist N")
+			}
+			number, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, false, fmt.Errorf("not a history entry number: %s", fields[1])
+			}
+			return travel(state, number-1-state.History.Current())
+		}
+	}
 
 	switch input {
 	case "":
@@ -104,16 +975,36 @@ func processUserInput(input string, state *State) (*url.URL, bool, error) {
 		return nil, true, nil
 
 	case "g":
-		linkRaw = "gemini://geminiprotocol.net:1965/"
+		if home == bookmarks.StartPage {
+			return nil, true, processBookmarkCommand(nil, state)
+		}
+		linkRaw = home
 
 	case "b":
-		if len(state.History) < 2 {
-			fmt.Println("\033[31mNo history yet\033[0m") //red
-			return nil, true, nil
+		return travel(state, -1)
+
+	case "f":
+		return travel(state, 1)
+
+	case "r":
+		if state.Last == nil {
+			return nil, false, fmt.Errorf("no page opened yet")
 		}
+		fmt.Println(">", state.Last)
+		state.Reload = true
+		return state.Last, false, nil
 
-		linkRaw = state.History[len(state.History)-2]
-		state.History = state.History[:len(state.History)-2]
+	case "t":
+		return nil, true, showTop(state)
+
+	case "i":
+		if state.Response == nil {
+			return nil, false, fmt.Errorf("no page opened yet")
+		}
+		for _, line := range state.Response.Describe() {
+			fmt.Println(line)
+		}
+		return nil, true, nil
 
 	case "l":
 		fmt.Println("Links:")
@@ -123,9 +1014,7 @@ func processUserInput(input string, state *State) (*url.URL, bool, error) {
 		}
 
 		fmt.Println("\nHistory:")
-		for _, l := range state.History {
-			fmt.Printf("%s\n", l)
-		}
+		printStack(state.History)
 		fmt.Println()
 
 		return nil, true, nil
@@ -158,11 +1047,116 @@ func processUserInput(input string, state *State) (*url.URL, bool, error) {
 	return link, false, nil
 }
 
+// travel requests page offset from the current one in history, position is changed once the page is shown
+func travel(state *State, offset int) (*url.URL, bool, error) {
+	linkRaw, ok := state.History.At(offset)
+	if !ok || offset == 0 {
+		fmt.Println("\033[31mNo such history entry\033[0m") //red
+		return nil, true, nil
+	}
+
+	fmt.Println(">", linkRaw)
+	link, err := gemini.GetFullGeminiLink(linkRaw)
+	if err != nil {
+		return nil, false, fmt.Errorf("error generating gemini URL: %w", err)
+	}
+
+	state.Travel = offset
+	return link, false, nil
+}
+
+// printStack lists history entries by number, current one is marked
+func printStack(stack *history.Stack) {
+	for i, l := range stack.Entries() {
+		marker := " "
+		if i == stack.Current() {
+			marker = "*"
+		}
+		fmt.Printf("%d%s %s\n", i+1, marker, l)
+	}
+}
+
+const visitsShown = 50
+
+// showVisits prints latest visits matching query and makes them links of state
+func showVisits(state *State, query string) {
+	if visits == nil {
+		fmt.Println("\033[31mGlobal history is not available\033[0m") //red
+		return
+	}
+
+	found := visits.Search(query, visitsShown)
+	if len(found) == 0 {
+		fmt.Println("Nothing found")
+		return
+	}
+
+	state.clearLinks()
+	for i, entry := range found {
+		state.Links = append(state.Links, entry.URL)
+		fmt.Printf("[%d] %s \u001B[34m%s\033[0m %s\n", i+1, entry.Time.Local().Format(time.DateTime), entry.URL, entry.Title)
+	}
+	fmt.Println()
+}
+
+// recordVisit updates session and global history once link is shown
+func recordVisit(state *State, link *url.URL, title string) {
+	if state.Travel == 0 || !state.History.Move(state.Travel) {
+		state.History.Visit(link.String())
+	}
+	state.Travel = 0
+	state.Title = title
+
+	if visits != nil {
+		if err := visits.Add(link.String(), title, time.Now()); err != nil {
+			fmt.Println("\033[31mstoring history failed:", err, "\033[0m") //red
+		}
+	}
+}
+
+// requestInput shows the prompt from meta and returns link with the answer as query
+func requestInput(reader *bufio.Reader, link *url.URL, response *gemini.Response) (*url.URL, error) {
+	fmt.Printf("\033[33m%s\033[0m\n", response.Meta) //orange
+
+	sensitive := response.Code == gemini.CodeSensitiveInput
+	if sensitive {
+		restore, err := term.DisableEcho(int(os.Stdin.Fd()))
+		if err != nil {
+			fmt.Println("\033[31mwarning: sensitive input will be visible as typed, echo cannot be turned off:", err, "\033[0m") //red
+		} else {
+			defer restore()
+		}
+	}
+	fmt.Print("✏️ ")
+
+	input, err := reader.ReadString('\n')
+	if sensitive {
+		fmt.Println()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("input read failed: %w", err)
+	}
+
+	input = strings.TrimRight(input, "\r\n")
+	if input == "" {
+		return nil, fmt.Errorf("input cancelled")
+	}
+
+	return gemini.WithQuery(link, input)
+}
+
 func processResponse(state *State, link *url.URL, response *gemini.Response) error {
+	state.Last = link
+	state.Title = ""
+
 	switch response.Status {
-	case gemini.StatusInput, gemini.StatusRedirect, gemini.StatusClientCertRequired:
+	case gemini.StatusInput, gemini.StatusRedirect:
+		// input is requested in the main loop, redirects are followed by the gemini package
 		return fmt.Errorf("unsupported status: %s", response.Meta)
 
+	case gemini.StatusClientCertRequired:
+		return fmt.Errorf("%w (see \"id new NAME\" or \"id use NAME\")", response.Err())
+
 	case gemini.StatusSuccess:
 		err := processSuccessfulResponse(state, link, response)
 		if err != nil {
@@ -170,70 +1164,129 @@ func processResponse(state *State, link *url.URL, response *gemini.Response) err
 		}
 
 	case gemini.StatusTemporaryFailure, gemini.StatusPermanentFailure:
-		return fmt.Errorf("ERROR: %s", response.Meta)
+		return fmt.Errorf("ERROR: %w", response.Err())
 	}
 
 	return nil
 }
 
 func processSuccessfulResponse(state *State, link *url.URL, response *gemini.Response) error {
-	if !strings.HasPrefix(response.Meta, "text/") {
+	if response.BodyReader != nil {
+		defer response.BodyReader.Close()
+	}
+
+	mediaType, err := response.MediaType()
+	if err != nil {
+		return err
+	}
+	if !mediaType.IsText() {
 		return fmt.Errorf("unsupported type: %s", response.Meta)
 	}
 
+	if response.BodyReader != nil {
+		bodyReader, err := gemini.NewCharsetReader(response.BodyReader, mediaType.Charset())
+		if err != nil {
+			return err
+		}
+
+		body, err := io.ReadAll(bodyReader)
+		if err != nil {
+			return err
+		}
+		response.Body = body
+	} else {
+		_, response.Body, err = gemini.DecodeText(mediaType, response.Body)
+		if err != nil {
+			return err
+		}
+	}
+
 	body := string(response.Body)
-	if strings.HasPrefix(response.Meta, gemini.GeminiMediaType) {
-		state.clearLinks()
-		preformatted := false
-
-		for _, line := range strings.Split(body, "\n") {
-			if strings.HasPrefix(line, "```") {
-				fmt.Println(line)
-				preformatted = !preformatted
-			} else if preformatted {
-				fmt.Println(line)
-			} else if strings.HasPrefix(line, LinkPrefix) {
-				err := processLink(state, link, line)
-				if err != nil {
-					return err
-				}
-			} else if strings.HasPrefix(line, Header3Prefix) {
-				fmt.Printf("\033[33m%s\033[0m\n", line) //orange
-			} else if strings.HasPrefix(line, Header2Prefix) {
-				fmt.Printf("\033[32m%s\033[0m\n", line) //green
-			} else if strings.HasPrefix(line, Header1Prefix) {
-				fmt.Printf("\033[31m%s\033[0m\n", line) //red
-			} else {
-				fmt.Println(line)
-			}
+	title := ""
+	if mediaType.IsGemtext() {
+		doc := gemtext.ParseString(body)
+		title = doc.Title()
+		if err := showDocument(state, doc, link); err != nil {
+			return err
 		}
 	} else {
-		// print as is
-		fmt.Print(body)
+		// plain text is paged like gemtext, its lines are shown as is
+		if err := showLines(state, strings.Split(strings.TrimSuffix(body, "\n"), "\n")); err != nil {
+			return err
+		}
 	}
 
-	state.History = append(state.History, link.String())
+	recordVisit(state, link, title)
 	return nil
 }
 
-func processLink(state *State, link *url.URL, line string) error {
-	line = line[2:]
-	parts := strings.Fields(line)
-	parsedLink, err := url.Parse(parts[0])
+// topHostsShown limits number of hosts listed by t
+const topHostsShown = 256
+
+// showTop lists hosts of cached pages as links, most pages first
+func showTop(state *State) error {
+	if cache == nil {
+		return fmt.Errorf("cache is disabled")
+	}
+	hosts, err := cache.DB.Hosts()
 	if err != nil {
-		return fmt.Errorf("parsing absoluteLink failed: %w", err)
+		return fmt.Errorf("read db dir failed: %w", err)
 	}
 
-	absoluteLink := link.ResolveReference(parsedLink).String()
-	var linkNum string
-	if len(parts) == 1 {
-		linkNum = absoluteLink
-	} else {
-		linkNum = strings.Join(parts[1:], " ")
+	var b strings.Builder
+	b.WriteString("# Top sites by pages\n\n")
+	if len(hosts) == 0 {
+		b.WriteString("No pages found in local DB\n")
+	}
+	for _, host := range hosts[:min(len(hosts), topHostsShown)] {
+		fmt.Fprintf(&b, "=> gemini://%s/ %s (%d pages)\n", host.Host, host.Host, host.Pages)
 	}
+	return showDocument(state, gemtext.ParseString(b.String()), nil)
+}
 
-	state.Links = append(state.Links, absoluteLink)
-	fmt.Printf("[%d] \u001B[34m%s\033[0m\n", len(state.Links), linkNum) //blue
+// showDocument makes links of doc resolved against base the links of state and shows it
+func showDocument(state *State, doc gemtext.Document, base *url.URL) error {
+	state.clearLinks()
+	for _, line := range doc.Links() {
+		if err := processLink(state, base, line); err != nil {
+			return err
+		}
+	}
+	return showLines(state, renderer.Lines(doc, base))
+}
+
+// showLines prints rendered page through the pager if it is enabled,
+// link number picked in the pager becomes the next command
+func showLines(state *State, lines []string) error {
+	if viewer == nil {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	// keys work without Enter while paging, terminal is restored for the prompt
+	if restore, err := term.Cbreak(int(os.Stdin.Fd())); err == nil {
+		defer restore()
+	}
+
+	number, err := viewer.Run(lines)
+	if err != nil {
+		return err
+	}
+	if number > 0 {
+		state.Next = strconv.Itoa(number)
+	}
+	return nil
+}
+
+// processLink resolves link against base and adds it to state, renderer shows it with the same number
+func processLink(state *State, base *url.URL, line gemtext.Link) error {
+	parsedLink, err := line.Resolve(base)
+	if err != nil {
+		return fmt.Errorf("parsing absoluteLink failed: %w", err)
+	}
 
+	state.Links = append(state.Links, parsedLink.String())
 	return nil
 }
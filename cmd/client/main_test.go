@@ -3,11 +3,22 @@ package main
 import (
 	"bufio"
 	"errors"
+	"io"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/romanthekat/gemini-tools/internal/bookmarks"
+	"github.com/romanthekat/gemini-tools/internal/feeds"
 	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/history"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+	"github.com/romanthekat/gemini-tools/internal/pager"
 )
 
 // Test getFullGeminiLink ensures proper handling of raw links.
@@ -17,7 +28,9 @@ func TestGetFullGeminiLink(t *testing.T) {
 		want   string
 		hasErr bool
 	}{
-		{"gemini://example.com", "gemini://example.com:1965", false},
+		{"gemini://example.com", "gemini://example.com:1965/", false},
+		{"gemini://Example.com/a/../b#top", "gemini://example.com:1965/b", false},
+		{"gemini://user@example.com/", "", true}, // userinfo not allowed
 		{"example.com/path", "gemini://example.com:1965/path", false},
 		{"gemini://example.com:1234/abc", "gemini://example.com:1234/abc", false},
 		{"gemini://[::1]/", "gemini://[::1]:1965/", false},
@@ -41,7 +54,7 @@ func TestProcessLink(t *testing.T) {
 	line := "=> /doc/gemtext.gmi Gemtext Document"
 
 	state := NewState()
-	if err := processLink(state, baseURL, line); err != nil {
+	if err := processLink(state, baseURL, gemtext.ParseLine(line).(gemtext.Link)); err != nil {
 		t.Fatalf("processLink returned error: %v", err)
 	}
 	if len(state.Links) != 1 {
@@ -160,13 +173,13 @@ func TestProcessUserInput(t *testing.T) {
 	}
 
 	// Back navigation with insufficient history
-	state.History = []string{"gemini://example.com:1965/a"}
+	state.History.Visit("gemini://example.com:1965/first")
 	if link, dn, err := processUserInput("b", state); err != nil || !dn || link != nil {
 		t.Fatalf("back insufficient unexpected: link=%v dn=%v err=%v", link, dn, err)
 	}
 
 	// Back navigation with history
-	state.History = []string{"gemini://example.com:1965/first", "gemini://example.com:1965/second"}
+	state.History.Visit("gemini://example.com:1965/second")
 	link, dn, err = processUserInput("b", state)
 	if err != nil || dn || link == nil {
 		t.Fatalf("back navigation unexpected: link=%v dn=%v err=%v", link, dn, err)
@@ -174,8 +187,80 @@ func TestProcessUserInput(t *testing.T) {
 	if link.String() != "gemini://example.com:1965/first" {
 		t.Errorf("back link mismatch: %s", link.String())
 	}
-	if len(state.History) != 0 {
-		t.Errorf("history not trimmed correctly, got %v", state.History)
+	if state.History.Current() != 1 || state.Travel != -1 {
+		t.Errorf("history should move only once the page is shown: current=%d travel=%d", state.History.Current(), state.Travel)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	state := NewState()
+	pages := []string{"gemini://example.com:1965/a", "gemini://example.com:1965/b", "gemini://example.com:1965/c"}
+	for _, page := range pages {
+		state.History.Visit(page)
+	}
+	success := func(link *url.URL) {
+		resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: "text/plain", Body: []byte("text\n")}
+		if err := processSuccessfulResponse(state, link, resp); err != nil {
+			t.Fatalf("processSuccessfulResponse error: %v", err)
+		}
+	}
+
+	// failed request keeps the position
+	if _, _, err := processUserInput("b", state); err != nil {
+		t.Fatalf("back failed: %v", err)
+	}
+	if _, _, err := processUserInput("hist 1", state); err != nil {
+		t.Fatalf("hist failed: %v", err)
+	}
+	link, _, _ := processUserInput("hist 1", state)
+	success(link)
+	if state.History.Current() != 0 || len(state.History.Entries()) != 3 {
+		t.Fatalf("jump should keep entries: current=%d %v", state.History.Current(), state.History.Entries())
+	}
+
+	link, dn, err := processUserInput("f", state)
+	if err != nil || dn || link.String() != pages[1] {
+		t.Fatalf("forward unexpected: link=%v dn=%v err=%v", link, dn, err)
+	}
+	success(link)
+	if state.History.Current() != 1 {
+		t.Fatalf("forward should move to the next entry: %d", state.History.Current())
+	}
+
+	// opening a new page drops forward entries
+	link, _, _ = processUserInput("example.com/d", state)
+	success(link)
+	if got := state.History.Entries(); len(got) != 3 || got[2] != "gemini://example.com:1965/d" {
+		t.Fatalf("unexpected entries: %v", got)
+	}
+	if link, dn, _ := processUserInput("f", state); !dn || link != nil {
+		t.Errorf("forward past the last entry should do nothing: %v", link)
+	}
+	if _, _, err := processUserInput("hist x", state); err == nil {
+		t.Errorf("expected error for malformed history number")
+	}
+}
+
+func TestShowVisits(t *testing.T) {
+	log, err := history.NewLog(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatalf("new log: %v", err)
+	}
+	visits = log
+	defer func() { visits = nil }()
+
+	state := NewState()
+	link, _ := url.Parse("gemini://example.com:1965/gemlog/")
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: gemini.GeminiMediaType, Body: []byte("# My gemlog\n")}
+	if err := processSuccessfulResponse(state, link, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+
+	if _, dn, err := processUserInput("log my GEMLOG", state); err != nil || !dn {
+		t.Fatalf("log unexpected: dn=%v err=%v", dn, err)
+	}
+	if len(state.Links) != 1 || state.Links[0] != link.String() {
+		t.Errorf("found visits should become links: %v", state.Links)
 	}
 }
 
@@ -230,7 +315,7 @@ func TestProcessSuccessfulResponseGemtext(t *testing.T) {
 		t.Errorf("links not processed as expected: %v", state.Links)
 	}
 	// History updated
-	if got := state.History; len(got) != 1 || got[0] != link.String() {
+	if got := state.History.Entries(); len(got) != 1 || got[0] != link.String() {
 		t.Errorf("history not updated: %v", got)
 	}
 }
@@ -249,7 +334,7 @@ func TestProcessSuccessfulResponsePlainText(t *testing.T) {
 		t.Errorf("links should not be modified for plain text: %v", state.Links)
 	}
 	// History updated
-	if got := state.History; len(got) != 1 || got[0] != link.String() {
+	if got := state.History.Entries(); len(got) != 1 || got[0] != link.String() {
 		t.Errorf("history not updated for plain text: %v", got)
 	}
 }
@@ -273,7 +358,7 @@ func TestProcessLinkMalformedURL(t *testing.T) {
 	state := NewState()
 	base, _ := url.Parse("gemini://example.com:1965/")
 	line := "=> %zz label"
-	if err := processLink(state, base, line); err == nil {
+	if err := processLink(state, base, gemtext.ParseLine(line).(gemtext.Link)); err == nil {
 		t.Fatalf("expected error for malformed URL in processLink")
 	}
 }
@@ -287,7 +372,449 @@ func TestProcessSuccessfulResponseUnsupportedType(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "unsupported type") {
 		t.Fatalf("expected unsupported type error, got %v", err)
 	}
-	if len(state.History) != 0 {
-		t.Fatalf("history should not be updated on error, got %v", state.History)
+	if len(state.History.Entries()) != 0 {
+		t.Fatalf("history should not be updated on error, got %v", state.History.Entries())
+	}
+}
+
+func TestProcessIdentityCommand(t *testing.T) {
+	store, err := gemini.NewIdentityStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := client.Identities
+	client.Identities = store
+	defer func() { client.Identities = previous }()
+
+	state := NewState()
+	if _, _, err := processUserInput("id use me", state); err == nil {
+		t.Fatalf("expected error without opened capsule")
+	}
+
+	state.Last, _ = url.Parse("gemini://station.martinrue.com:1965/some/page")
+	if link, dn, err := processUserInput("id new me", state); err != nil || !dn || link != nil {
+		t.Fatalf("id new unexpected: link=%v dn=%v err=%v", link, dn, err)
+	}
+
+	other, _ := url.Parse("gemini://station.martinrue.com/other")
+	if identity := store.ForURL(other); identity == nil || identity.Name != "me" {
+		t.Fatalf("identity should cover the whole capsule, got %+v", identity)
+	}
+
+	if _, _, err := processUserInput("id del me", state); err != nil {
+		t.Fatalf("id del: %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Fatalf("identity not deleted")
+	}
+}
+
+func TestRequestInput(t *testing.T) {
+	link, _ := url.Parse("gemini://example.com:1965/search?old")
+	resp := &gemini.Response{Status: gemini.StatusInput, Code: gemini.CodeInput, Meta: "Search query"}
+
+	reader := bufio.NewReader(strings.NewReader("gemini & go+more/?\n"))
+	got, err := requestInput(reader, link, resp)
+	if err != nil {
+		t.Fatalf("requestInput error: %v", err)
+	}
+	want := "gemini://example.com:1965/search?gemini%20%26%20go%2Bmore%2F%3F"
+	if got.String() != want {
+		t.Errorf("expected %q, got %q", want, got.String())
+	}
+	if link.RawQuery != "old" {
+		t.Errorf("original link modified: %s", link)
+	}
+
+	// empty answer cancels the request
+	reader = bufio.NewReader(strings.NewReader("\n"))
+	if _, err := requestInput(reader, link, resp); err == nil {
+		t.Fatalf("expected error for empty input")
+	}
+}
+
+func TestRequestSensitiveInputWarns(t *testing.T) {
+	// stdin which is not a terminal cannot have echo turned off
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, write
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	link, _ := url.Parse("gemini://example.com/login")
+	resp := &gemini.Response{Status: gemini.StatusInput, Code: gemini.CodeSensitiveInput, Meta: "Password"}
+	_, err = requestInput(bufio.NewReader(strings.NewReader("secret\n")), link, resp)
+	write.Close()
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	if err != nil {
+		t.Fatalf("requestInput error: %v", err)
+	}
+
+	output, _ := io.ReadAll(read)
+	if !strings.Contains(string(output), "sensitive input will be visible") {
+		t.Fatalf("expected warning, got %q", output)
+	}
+}
+
+func TestWithQueryTooLong(t *testing.T) {
+	link, _ := url.Parse("gemini://example.com:1965/search")
+	if _, err := gemini.WithQuery(link, strings.Repeat("a", gemini.MaxURLLength)); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected too long error, got %v", err)
+	}
+}
+
+func TestProcessSuccessfulResponseStreamed(t *testing.T) {
+	state := NewState()
+	link, _ := url.Parse("gemini://example.com:1965/streamed")
+
+	body := io.NopCloser(strings.NewReader("# Title\n=> /next Next\n"))
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: gemini.GeminiMediaType, BodyReader: body}
+	if err := processSuccessfulResponse(state, link, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+	if len(state.Links) != 1 || state.Links[0] != "gemini://example.com:1965/next" {
+		t.Errorf("links not parsed from streamed body: %v", state.Links)
+	}
+
+	plain := io.NopCloser(strings.NewReader("plain text\n"))
+	resp = &gemini.Response{Status: gemini.StatusSuccess, Meta: "text/plain", BodyReader: plain}
+	plainLink, _ := url.Parse("gemini://example.com:1965/plain.txt")
+	if err := processSuccessfulResponse(state, plainLink, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+	if len(state.History.Entries()) != 2 {
+		t.Errorf("history not updated for streamed responses: %v", state.History.Entries())
+	}
+}
+
+func TestProcessSuccessfulResponseCharset(t *testing.T) {
+	state := NewState()
+	link, _ := url.Parse("gemini://example.com:1965/latin1")
+
+	// "=> /caf\xE9 Caf\xE9" in latin-1
+	resp := &gemini.Response{
+		Status: gemini.StatusSuccess,
+		Meta:   "text/gemini; charset=ISO-8859-1; lang=fr",
+		Body:   []byte("=> /caf\xE9 Caf\xE9\n"),
+	}
+	if err := processSuccessfulResponse(state, link, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+	if string(resp.Body) != "=> /café Café\n" {
+		t.Errorf("body not decoded: %q", resp.Body)
+	}
+	if len(state.Links) != 1 || state.Links[0] != "gemini://example.com:1965/caf%C3%A9" {
+		t.Errorf("unexpected links: %v", state.Links)
+	}
+
+	resp = &gemini.Response{Status: gemini.StatusSuccess, Meta: "text/plain; charset=shift_jis", Body: []byte("x")}
+	if err := processSuccessfulResponse(state, link, resp); err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Errorf("expected unsupported charset error, got %v", err)
+	}
+}
+
+// Test processSuccessfulResponse does not panic on link lines without URL
+func TestProcessSuccessfulResponseBareLink(t *testing.T) {
+	state := NewState()
+	link, _ := url.Parse("gemini://example.com:1965/")
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: "text/gemini", Body: []byte("=>\n=>   \n=> /next Next\n")}
+	if err := processSuccessfulResponse(state, link, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+	if len(state.Links) != 1 || state.Links[0] != "gemini://example.com:1965/next" {
+		t.Errorf("unexpected links: %v", state.Links)
+	}
+}
+
+// Test link number picked in the pager becomes the next command
+func TestProcessSuccessfulResponsePlainTextPaged(t *testing.T) {
+	defer func() { viewer = nil }()
+	var out strings.Builder
+	viewer = pager.New(bufio.NewReader(strings.NewReader("q\n")), &out, 3)
+
+	state := NewState()
+	link, _ := url.Parse("gemini://example.com:1965/long.txt")
+	body := io.NopCloser(strings.NewReader("line 1\nline 2\nline 3\nline 4\nline 5\n"))
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: "text/plain", BodyReader: body}
+	if err := processSuccessfulResponse(state, link, resp); err != nil {
+		t.Fatalf("processSuccessfulResponse error: %v", err)
+	}
+	// quitting on the first screen leaves the rest unshown
+	if !strings.Contains(out.String(), "line 1") || strings.Contains(out.String(), "line 5") {
+		t.Fatalf("plain text should be paged: %q", out.String())
+	}
+}
+
+func TestShowLinesPagerLink(t *testing.T) {
+	defer func() { viewer = nil }()
+	viewer = pager.New(bufio.NewReader(strings.NewReader("2\n")), io.Discard, 3)
+
+	state := NewState()
+	if err := showLines(state, []string{"a", "b", "c", "d"}); err != nil {
+		t.Fatalf("showLines error: %v", err)
+	}
+	if state.Next != "2" {
+		t.Fatalf("expected next command 2, got %q", state.Next)
+	}
+}
+
+func TestTabs(t *testing.T) {
+	tabs := NewTabs()
+	tabs.Current().Links = []string{"gemini://example.com:1965/a"}
+
+	link, doNothing, err := processTabCommand([]string{"new", "1"}, tabs)
+	if err != nil || doNothing || link.String() != "gemini://example.com:1965/a" {
+		t.Fatalf("tab new unexpected: link=%v dn=%v err=%v", link, doNothing, err)
+	}
+	if len(tabs.States) != 2 || tabs.Active != 1 || len(tabs.Current().Links) != 0 {
+		t.Fatalf("new tab should be current and empty: %+v", tabs)
+	}
+
+	if _, _, err := processTabCommand([]string{"new", "5"}, tabs); err == nil {
+		t.Errorf("expected error for missing link number")
+	}
+	if _, _, err := processTabCommand([]string{"use", "3"}, tabs); err == nil {
+		t.Errorf("expected error for missing tab")
+	}
+
+	if _, _, err := processTabCommand([]string{"use", "1"}, tabs); err != nil || tabs.Active != 0 {
+		t.Fatalf("tab use failed: active=%d err=%v", tabs.Active, err)
+	}
+	if _, _, err := processTabCommand([]string{"del"}, tabs); err != nil {
+		t.Fatalf("tab del failed: %v", err)
+	}
+	if len(tabs.States) != 1 || tabs.Active != 0 || len(tabs.Current().Links) != 0 {
+		t.Fatalf("second tab should remain: %+v", tabs)
+	}
+	if _, _, err := processTabCommand([]string{"del"}, tabs); err == nil {
+		t.Errorf("expected error closing the last tab")
+	}
+}
+
+func TestProcessBookmarkCommand(t *testing.T) {
+	state := NewState()
+	if err := processBookmarkCommand(nil, state); err == nil {
+		t.Fatalf("expected error without bookmarks store")
+	}
+
+	store, err := bookmarks.NewStore(filepath.Join(t.TempDir(), "bookmarks"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	saved = store
+	defer func() { saved = nil }()
+
+	if err := processBookmarkCommand([]string{"add"}, state); err == nil {
+		t.Fatalf("expected error without opened page")
+	}
+
+	link, _ := url.Parse("gemini://example.com:1965/gemlog/")
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: gemini.GeminiMediaType, Body: []byte("# My gemlog\n")}
+	if err := processResponse(state, link, resp); err != nil {
+		t.Fatalf("processResponse error: %v", err)
+	}
+	if err := processBookmarkCommand([]string{"add", "#blogs"}, state); err != nil {
+		t.Fatalf("bm add failed: %v", err)
+	}
+	if got := store.List("blogs"); len(got) != 1 || got[0].Title != "My gemlog" || got[0].URL != link.String() {
+		t.Fatalf("unexpected bookmarks: %+v", got)
+	}
+
+	// bookmarks page becomes current links, g shows it when it is the home page
+	home = bookmarks.StartPage
+	defer func() { home = defaultHome }()
+	if link, dn, err := processUserInput("g", state); err != nil || !dn || link != nil {
+		t.Fatalf("g unexpected: link=%v dn=%v err=%v", link, dn, err)
+	}
+	if len(state.Links) != 1 || state.Links[0] != link.String() {
+		t.Errorf("bookmarks should become links: %v", state.Links)
+	}
+
+	// numbers refer to the last shown list, filtered by tag
+	if err := store.Add("gemini://other.org/", "Other", []string{"misc"}, time.Now()); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := processBookmarkCommand([]string{"#misc"}, state); err != nil {
+		t.Fatalf("bm #misc failed: %v", err)
+	}
+	if err := processBookmarkCommand([]string{"del", "1"}, state); err != nil {
+		t.Fatalf("bm del failed: %v", err)
+	}
+	if got := store.List(""); len(got) != 1 || got[0].URL != link.String() {
+		t.Errorf("wrong bookmark deleted: %+v", got)
+	}
+
+	if err := processBookmarkCommand(nil, state); err != nil {
+		t.Fatalf("bm failed: %v", err)
+	}
+	if err := processBookmarkCommand([]string{"del", "1"}, state); err != nil {
+		t.Fatalf("bm del failed: %v", err)
+	}
+	if len(store.List("")) != 0 {
+		t.Errorf("bookmark not deleted")
+	}
+}
+
+func TestProcessSubscriptionCommand(t *testing.T) {
+	state := NewState()
+	if err := processSubscriptionCommand(nil, state); err == nil {
+		t.Fatalf("expected error without subscriptions")
+	}
+
+	subs, err := feeds.NewSubscriptions(t.TempDir())
+	if err != nil {
+		t.Fatalf("new subscriptions: %v", err)
+	}
+	subscriptions = subs
+	defer func() { subscriptions = nil }()
+
+	if err := processSubscriptionCommand([]string{"add"}, state); err == nil || !strings.Contains(err.Error(), "no page opened") {
+		t.Fatalf("expected error without opened page, got %v", err)
+	}
+	if err := subs.Subscribe(feeds.Feed{URL: "gemini://example.com:1965/gemlog/", Title: "Gemlog"}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err := processSubscriptionCommand(nil, state); err != nil {
+		t.Fatalf("sub failed: %v", err)
+	}
+	if err := processSubscriptionCommand([]string{"del", "2"}, state); err == nil {
+		t.Errorf("expected error for missing subscription")
+	}
+	if err := processSubscriptionCommand([]string{"del", "1"}, state); err != nil || len(subs.List()) != 0 {
+		t.Errorf("sub del failed: %v", err)
+	}
+}
+
+func TestOfferDownload(t *testing.T) {
+	previous := downloadDir
+	downloadDir = t.TempDir()
+	defer func() { downloadDir = previous }()
+	link, _ := url.Parse("gemini://example.com:1965/images/cat.png")
+	mediaType, _ := gemini.ParseMediaType("image/png")
+
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: "image/png", Body: []byte("png")}
+	if err := offerDownload(bufio.NewReader(strings.NewReader("n\n")), link, mediaType, resp); err != nil {
+		t.Fatalf("skip failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(downloadDir); len(entries) != 0 {
+		t.Fatalf("nothing should be saved when skipped: %v", entries)
+	}
+
+	resp = &gemini.Response{Status: gemini.StatusSuccess, Meta: "image/png", BodyReader: io.NopCloser(strings.NewReader("png"))}
+	if err := offerDownload(bufio.NewReader(strings.NewReader("s\n")), link, mediaType, resp); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if body, err := os.ReadFile(filepath.Join(downloadDir, "cat.png")); err != nil || string(body) != "png" {
+		t.Errorf("unexpected saved file: %q %v", body, err)
+	}
+}
+
+func TestNavigateCache(t *testing.T) {
+	previous := cache
+	cache = pagedb.NewCache(t.TempDir(), time.Hour)
+	defer func() { cache = previous }()
+
+	// nothing listens on port 1, so requests fail right away
+	link, _ := url.Parse("gemini://127.0.0.1:1/")
+	page := gemini.NewResponseCode(gemini.CodeSuccess, gemini.GeminiMediaType, []byte("# Cached\n=> /next next\n"))
+	if err := cache.Put(link, page); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	reader := bufio.NewReader(strings.NewReader(""))
+
+	state := NewState()
+	if err := navigate(reader, state, link); err != nil {
+		t.Fatalf("fresh page should be shown from cache: %v", err)
+	}
+	if state.Title != "Cached" || len(state.Links) != 1 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	// r reloads skipping cache, unreachable capsule falls back to stale copy
+	state = NewState()
+	state.Last = link
+	reloadLink, doNothing, err := processUserInput("r", state)
+	if err != nil || doNothing || reloadLink != link || !state.Reload {
+		t.Fatalf("unexpected reload: %v %v %v", reloadLink, doNothing, err)
+	}
+	if err := navigate(reader, state, reloadLink); err != nil {
+		t.Fatalf("stale page should be shown: %v", err)
+	}
+	if state.Reload || state.Title != "Cached" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	missing, _ := url.Parse("gemini://127.0.0.1:1/missing")
+	if err := navigate(reader, NewState(), missing); err == nil {
+		t.Fatalf("expected request error for page not in cache")
+	}
+}
+
+func TestNavigateInputCancelled(t *testing.T) {
+	previous, previousDial := cache, client.DialTLSContext
+	cache = pagedb.NewCache(t.TempDir(), time.Hour)
+	client.DialTLSContext = geminitest.Dialer(map[string]string{"gemini://example.org:1965/search": "10 Query\r\n"})
+	defer func() { cache, client.DialTLSContext = previous, previousDial }()
+
+	// empty answer cancels the request instead of looking for a stale copy
+	link, _ := url.Parse("gemini://example.org:1965/search")
+	reader := bufio.NewReader(strings.NewReader("\n"))
+	if err := navigate(reader, NewState(), link); err == nil || !strings.Contains(err.Error(), "input cancelled") {
+		t.Fatalf("expected cancelled input, got %v", err)
+	}
+}
+
+func TestNavigateOffline(t *testing.T) {
+	previous := cache
+	dir := t.TempDir()
+	cache = &pagedb.Cache{DB: pagedb.New(dir), TTL: pagedb.Forever, Offline: true, Queue: filepath.Join(dir, "queue.txt")}
+	defer func() { cache = previous }()
+
+	link, _ := url.Parse("gemini://127.0.0.1:1/")
+	page := gemini.NewResponseCode(gemini.CodeSuccess, gemini.GeminiMediaType, []byte("# Local\n=> /next next\n"))
+	if err := cache.Put(link, page); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	reader := bufio.NewReader(strings.NewReader(""))
+
+	state := NewState()
+	state.Reload = true
+	if err := navigate(reader, state, link); err != nil || state.Title != "Local" {
+		t.Fatalf("page should be read from DB: %v", err)
+	}
+
+	missing, _ := url.Parse("gemini://127.0.0.1:1/missing")
+	if err := navigate(reader, state, missing); err == nil || !strings.Contains(err.Error(), "queued") {
+		t.Fatalf("missing page should be queued: %v", err)
+	}
+	if queue, _ := os.ReadFile(cache.Queue); string(queue) != "gemini://127.0.0.1:1/missing\n" {
+		t.Fatalf("unexpected queue: %q", queue)
+	}
+
+	if err := showTop(state); err != nil {
+		t.Fatalf("top: %v", err)
+	}
+	if len(state.Links) != 1 || state.Links[0] != "gemini://127.0.0.1:1/" {
+		t.Fatalf("unexpected top links: %v", state.Links)
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	state := NewState()
+	if _, _, err := processUserInput("i", state); err == nil {
+		t.Fatalf("expected error without opened page")
+	}
+
+	state.Response = gemini.NewResponseCode(gemini.CodeNotFound, "no such page", nil)
+	if link, doNothing, err := processUserInput("i", state); err != nil || !doNothing || link != nil {
+		t.Fatalf("unexpected result: %v %v %v", link, doNothing, err)
 	}
 }
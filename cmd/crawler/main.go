@@ -4,34 +4,51 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/romanthekat/gemini-tools/internal/config"
 	"github.com/romanthekat/gemini-tools/internal/crawler"
 )
 
 func main() {
+	cfg, cfgErr := config.LoadDefault()
+	defaults := cfg.Crawler
+
 	var (
-		queuePath    = flag.String("queue", "queue.txt", "path to queue file (one URL per line)")
-		dbDir        = flag.String("db", "data", "database root directory")
-		errorLogPath = flag.String("error-log", "error_queue.log", "path to error log file")
-		throttleMS   = flag.Int("throttle-ms", 1500, "per-host minimum interval between requests in milliseconds")
-		recrawlHours = flag.Int("recrawl-hours", 24*32, "do not recrawl a page within this many hours")
-		maxRespKB    = flag.Int("max-kb", 500, "maximum response size to save (in KB)")
-		workers      = flag.Int("workers", 4, "number of concurrent workers")
+		queuePath    = flag.String("queue", defaults.Queue, "path to queue file (one URL per line)")
+		dbDir        = flag.String("db", defaults.DB, "database root directory")
+		errorLogPath = flag.String("error-log", defaults.ErrorLog, "path to error log file")
+		knownHosts   = flag.String("known-hosts", defaults.KnownHosts, "path to pinned certificates file (default <db>/known_hosts)")
+		throttleMS   = flag.Int("throttle-ms", defaults.ThrottleMS, "per-host minimum interval between requests in milliseconds")
+		recrawlHours = flag.Int("recrawl-hours", defaults.RecrawlHours, "do not recrawl a page within this many hours")
+		maxRespKB    = flag.Int("max-kb", defaults.MaxKB, "maximum response size to save (in KB)")
+		workers      = flag.Int("workers", defaults.Workers, "number of concurrent workers")
 	)
 	flag.Parse()
 
+	if cfgErr != nil {
+		fmt.Println("config ignored:", cfgErr)
+	}
+
 	opts := crawler.Options{
-		DBDir:         *dbDir,
-		QueuePath:     *queuePath,
-		ErrorLogPath:  *errorLogPath,
-		Throttle:      time.Duration(*throttleMS) * time.Millisecond,
-		RecrawlWindow: time.Duration(*recrawlHours) * time.Hour,
-		MaxResponseKB: *maxRespKB,
-		Workers:       *workers,
+		DBDir:          *dbDir,
+		QueuePath:      *queuePath,
+		ErrorLogPath:   *errorLogPath,
+		KnownHostsPath: *knownHosts,
+		Throttle:       time.Duration(*throttleMS) * time.Millisecond,
+		RecrawlWindow:  time.Duration(*recrawlHours) * time.Hour,
+		MaxResponseKB:  *maxRespKB,
+		Workers:        *workers,
+		Timeouts:       cfg.Timeouts,
 	}
 
-	c := crawler.New(opts, context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := crawler.New(opts, ctx)
 	if err := c.Run(); err != nil {
 		fmt.Println("crawler error:", err)
 	}
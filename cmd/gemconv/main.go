@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/romanthekat/gemini-tools/internal/convert"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// extensions of output files written with -d
+var extensions = map[string]string{"html": ".html", "md": ".md", "text": ".txt", "gmi": ".gmi"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// converter turns input documents into the output format
+type converter struct {
+	from, to string
+	// ext and proxy rewrite links, see convert.RewriteLinks
+	ext, proxy string
+	opts       convert.Options
+	// dir keeps converted files named after inputs, empty means stdout
+	dir string
+}
+
+// run converts files given in args, or stdin if there are none, and returns exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gemconv", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gemconv [flags] [FILE...]")
+		fmt.Fprintln(stderr, "converts gemtext or Markdown files, or stdin, to HTML, Markdown, plain text or gemtext")
+		flags.PrintDefaults()
+	}
+
+	c := &converter{}
+	var templatePath string
+	flags.StringVar(&c.to, "to", "html", "output format: html, md, text or gmi")
+	flags.StringVar(&c.from, "from", "", "input format: gmi or md, by default detected from file extension, gmi for stdin")
+	flags.StringVar(&templatePath, "template", "", "HTML template FILE, see html/template; gets .Title, .Lang and .Body")
+	flags.StringVar(&c.opts.Lang, "lang", "", "HTML page language, by default lang of pages stored by the crawler")
+	flags.IntVar(&c.opts.Width, "width", 80, "wrap plain text to N columns, 0 disables wrapping")
+	flags.StringVar(&c.ext, "ext", "", "rewrite relative links to .gmi files to this extension, e.g. .html")
+	flags.StringVar(&c.proxy, "proxy", "", "prefix gemini links with this proxy URL, e.g. https://proxy.example/gemini/")
+	flags.StringVar(&c.dir, "d", "", "write converted files into DIR instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if _, ok := extensions[c.to]; !ok {
+		fmt.Fprintln(stderr, "unknown output format:", c.to)
+		return exitUsage
+	}
+	if c.from != "" && c.from != "gmi" && c.from != "md" {
+		fmt.Fprintln(stderr, "unknown input format:", c.from)
+		return exitUsage
+	}
+	if templatePath != "" {
+		tmpl, err := template.ParseFiles(templatePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return exitUsage
+		}
+		c.opts.Template = tmpl
+	}
+
+	if flags.NArg() == 0 {
+		if c.dir != "" {
+			fmt.Fprintln(stderr, "-d requires input files")
+			return exitUsage
+		}
+		if err := c.convert("", stdin, stdout); err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	code := exitOK
+	for _, path := range flags.Args() {
+		if err := c.convertFile(path, stdout); err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", path, err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+func (c *converter) convertFile(path string, stdout io.Writer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if c.dir == "" {
+		return c.convert(path, in, stdout)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + extensions[c.to]
+	outPath := filepath.Join(c.dir, name)
+	if filepath.Clean(outPath) == filepath.Clean(path) {
+		return errors.New("output would overwrite input")
+	}
+	if err := os.MkdirAll(c.dir, pagedb.PermissionsFull); err != nil {
+		return err
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err := c.convert(path, in, out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// convert reads document from in and writes it to out, path is empty for stdin
+func (c *converter) convert(path string, in io.Reader, out io.Writer) error {
+	from := c.from
+	if from == "" {
+		from = "gmi"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".md" || ext == ".markdown" {
+			from = "md"
+		}
+	}
+
+	var doc gemtext.Document
+	var err error
+	if from == "md" {
+		doc, err = convert.FromMarkdown(in)
+	} else {
+		doc, err = gemtext.Parse(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := c.opts
+	var base *url.URL
+	if meta, ok := storedMeta(path); ok {
+		if opts.Lang == "" {
+			opts.Lang = meta.Lang
+		}
+		base = metaURL(meta)
+	}
+	opts.Link = linkRewriter(base, convert.RewriteLinks(c.ext, c.proxy))
+
+	var result string
+	switch c.to {
+	case "html":
+		result, err = convert.HTML(doc, opts)
+	case "md":
+		result = convert.Markdown(doc, opts)
+	case "text":
+		result = convert.Text(doc, opts)
+	default:
+		result = doc.String()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, result)
+	return err
+}
+
+// storedMeta reads meta of a page stored by the crawler or the client cache, kept in meta dir next to the page
+func storedMeta(path string) (pagedb.Meta, bool) {
+	if path == "" {
+		return pagedb.Meta{}, false
+	}
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(path), "meta", id+".meta.json"))
+	if err != nil {
+		return pagedb.Meta{}, false
+	}
+
+	var meta pagedb.Meta
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return pagedb.Meta{}, false
+	}
+	return meta, true
+}
+
+// metaURL is the URL stored page was received from, its links are relative to it
+func metaURL(meta pagedb.Meta) *url.URL {
+	raw := meta.URL
+	if len(meta.Redirects) > 0 {
+		raw = meta.Redirects[len(meta.Redirects)-1]
+	}
+	link, err := url.Parse(raw)
+	if err != nil || !link.IsAbs() {
+		return nil
+	}
+	return link
+}
+
+// linkRewriter resolves relative links of stored pages against their URL, as linked pages are not stored
+// next to them, then applies rewrite
+func linkRewriter(base *url.URL, rewrite func(string) string) func(string) string {
+	return func(target string) string {
+		if base != nil {
+			if resolved, err := (gemtext.Link{URL: target}).Resolve(base); err == nil {
+				target = resolved.String()
+			}
+		}
+		return rewrite(target)
+	}
+}
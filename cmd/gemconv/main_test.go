@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+)
+
+func TestRunStdin(t *testing.T) {
+	tests := []struct {
+		args  []string
+		input string
+		want  string
+	}{
+		{[]string{"--to=md"}, "=> notes.gmi Notes", "[Notes](notes.gmi)\n"},
+		{[]string{"--to=md", "--ext=.md"}, "=> notes.gmi Notes", "[Notes](notes.md)\n"},
+		{[]string{"--to=text", "--width=0"}, "# Title\n=> gemini://a.org/", "Title\n=====\n[1] gemini://a.org/\n\nLinks:\n[1] gemini://a.org/\n"},
+		{[]string{"--from=md", "--to=gmi"}, "## Title\n\n[a](gemini://a.org/)", "## Title\n\n=> gemini://a.org/ a\n"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, strings.NewReader(test.input), &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: exit code %d, stderr %s", test.args, code, stderr.String())
+		}
+		if stdout.String() != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, stdout.String(), test.want)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{{"--to=pdf"}, {"--from=rst"}, {"-d", t.TempDir()}, {"--template=missing.tmpl"}} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: exit code %d, want %d", args, code, exitUsage)
+		}
+	}
+}
+
+func TestRunStoredPage(t *testing.T) {
+	db := pagedb.New(t.TempDir())
+	link, _ := url.Parse("gemini://example.org/gemlog/")
+	resp := gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini; lang=de", []byte("# Post\n=> first.gmi First\n"))
+	if err := db.Save(link, resp); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	host, id := pagedb.PageID(link)
+	page := db.ContentPath(host, id, "text/gemini")
+
+	out := t.TempDir()
+	templatePath := filepath.Join(t.TempDir(), "page.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{.Lang}} {{.Title}}\n{{.Body}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-d", out, "--template", templatePath, "--proxy=https://proxy.example/", page}
+	if code := run(args, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d, stderr %s", code, stderr.String())
+	}
+
+	html, err := os.ReadFile(filepath.Join(out, id+".html"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	// relative links of stored pages point to their capsule, lang comes from stored meta
+	want := "de Post\n<h1>Post</h1>\n<ul class=\"links\">\n" +
+		"<li><a href=\"https://proxy.example/example.org/gemlog/first.gmi\">First</a></li>\n</ul>\n"
+	if string(html) != want {
+		t.Fatalf("got %q, want %q", html, want)
+	}
+}
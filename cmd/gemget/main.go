@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/config"
+	"github.com/romanthekat/gemini-tools/internal/download"
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+// exit codes besides response ones: failure responses exit with their status class times ten,
+// e.g. 10 for input request without --input, 40 for temporary and 50 for permanent failures
+const (
+	exitOK = 0
+	// exitError is for requests which failed without response, e.g. network or TLS errors, and output errors
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// fetcher requests URLs one by one, writing bodies to stdout, file or dir and headers to stderr
+type fetcher struct {
+	client *gemini.Client
+	stdout io.Writer
+	stderr io.Writer
+
+	// output is file for the body, empty or "-" means stdout
+	output string
+	// dir keeps bodies named after URLs, used instead of output if set
+	dir        string
+	headerOnly bool
+	// timeout limits each request including body, zero means per-phase client timeouts only
+	timeout time.Duration
+	// input answers status 1x if hasInput is set
+	input    string
+	hasInput bool
+}
+
+// run fetches URLs given in args and returns exit code of the first failed one
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, cfgErr := config.LoadDefault()
+
+	flags := flag.NewFlagSet("gemget", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gemget [flags] URL...")
+		fmt.Fprintln(stderr, "bodies are written to stdout and headers to stderr, exit code is 0 for success,")
+		fmt.Fprintln(stderr, "1 for failed requests, 2 for wrong usage and status class times ten for other responses")
+		flags.PrintDefaults()
+	}
+
+	f := &fetcher{stdout: stdout, stderr: stderr}
+	maxSize := byteSize(int64(cfg.Client.MaxMB) << 20)
+	flags.StringVar(&f.output, "o", "", "write body to FILE, - for stdout; only with a single URL")
+	flags.StringVar(&f.dir, "d", "", "save bodies into DIR, named after their URLs")
+	flags.BoolVar(&f.headerOnly, "header-only", false, "print response header only, body is not read")
+	flags.Var(&maxSize, "max-size", "maximum body size like 512K or 10M, 0 means unlimited")
+	flags.DurationVar(&f.timeout, "timeout", 0, "limit each request including body, e.g. 30s; 0 means only per-phase config timeouts")
+	flags.StringVar(&f.input, "input", "", "answer to input request (status 1x)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	flags.Visit(func(set *flag.Flag) {
+		f.hasInput = f.hasInput || set.Name == "input"
+	})
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if f.output != "" && f.output != "-" && (flags.NArg() > 1 || f.dir != "") {
+		fmt.Fprintln(stderr, "-o FILE accepts a single URL and cannot be combined with -d")
+		return exitUsage
+	}
+
+	if cfgErr != nil {
+		fmt.Fprintln(stderr, "config ignored:", cfgErr)
+	}
+	f.client = gemini.NewClient()
+	f.client.Timeouts = cfg.Timeouts
+	f.client.MaxBodySize = int64(maxSize)
+	if err := loadCertificates(f.client); err != nil {
+		fmt.Fprintln(stderr, "certificates not loaded:", err)
+	}
+
+	code := exitOK
+	for _, raw := range flags.Args() {
+		if flags.NArg() > 1 {
+			fmt.Fprintln(stderr, ">", raw)
+		}
+
+		result := exitUsage
+		link, err := gemini.GetFullGeminiLink(raw)
+		if err == nil {
+			result, err = f.fetch(link)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+		}
+		if code == exitOK {
+			code = result
+		}
+	}
+	return code
+}
+
+// loadCertificates pins server certificates and presents client ones like cmd/client does
+func loadCertificates(client *gemini.Client) error {
+	path, err := gemini.DefaultKnownHostsPath()
+	if err != nil {
+		return err
+	}
+	if client.KnownHosts, err = gemini.NewFileKnownHosts(path); err != nil {
+		return err
+	}
+
+	dir, err := gemini.DefaultIdentitiesDir()
+	if err != nil {
+		return err
+	}
+	client.Identities, err = gemini.NewIdentityStore(dir)
+	return err
+}
+
+// fetch requests link, answering input request once if input is set, and returns exit code
+func (f *fetcher) fetch(link *url.URL) (int, error) {
+	ctx := context.Background()
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	response, err := f.request(ctx, link)
+	if err == nil && response.Status == gemini.StatusInput && f.hasInput {
+		if link, err = gemini.WithQuery(response.URL, f.input); err != nil {
+			return exitUsage, err
+		}
+		response, err = f.request(ctx, link)
+	}
+	if err != nil {
+		return exitError, err
+	}
+
+	if response.Status != gemini.StatusSuccess {
+		return response.Status * 10, nil
+	}
+	defer response.BodyReader.Close()
+	if f.headerOnly {
+		return exitOK, nil
+	}
+
+	if err := f.writeBody(response); err != nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+// request streams link, printing headers of every response, redirects included
+func (f *fetcher) request(ctx context.Context, link *url.URL) (*gemini.Response, error) {
+	f.client.OnResponse = func(_ *url.URL, response *gemini.Response) {
+		fmt.Fprintf(f.stderr, "%d %s\n", response.Code, response.Meta)
+	}
+	return f.client.Stream(ctx, link)
+}
+
+func (f *fetcher) writeBody(response *gemini.Response) error {
+	switch {
+	case f.dir != "":
+		mediaType, err := response.MediaType()
+		if err != nil {
+			return err
+		}
+		path, err := download.Save(f.dir, download.FileName(response.URL, mediaType), response.BodyReader)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(f.stderr, "saved to", path)
+		return nil
+
+	case f.output != "" && f.output != "-":
+		file, err := os.Create(f.output)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, response.BodyReader)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// don't leave a truncated body behind
+			_ = os.Remove(f.output)
+		}
+		return err
+
+	default:
+		_, err := io.Copy(f.stdout, response.BodyReader)
+		return err
+	}
+}
+
+// byteSize is a flag value in bytes, accepting K, M and G suffixes
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	multiplier := int64(1)
+	upper := strings.TrimSuffix(strings.ToUpper(value), "B")
+	for suffix, m := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(upper, suffix) {
+			multiplier = m
+			upper = strings.TrimSuffix(upper, suffix)
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return errors.New("expected size like 4096, 512K or 10M")
+	}
+	*s = byteSize(n * multiplier)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
+)
+
+// newTestFetcher serves responses from memory, keyed by request line
+func newTestFetcher(responses map[string]string) (*fetcher, *bytes.Buffer, *bytes.Buffer) {
+	client := gemini.NewClient()
+	client.DialTLSContext = geminitest.Dialer(responses)
+
+	var stdout, stderr bytes.Buffer
+	return &fetcher{client: client, stdout: &stdout, stderr: &stderr}, &stdout, &stderr
+}
+
+func TestFetch(t *testing.T) {
+	responses := map[string]string{
+		"gemini://example.org:1965/":             "20 text/gemini\r\n# Hello\n",
+		"gemini://example.org:1965/old":          "31 /\r\n",
+		"gemini://example.org:1965/search":       "10 Query\r\n",
+		"gemini://example.org:1965/search?a%20b": "20 text/plain\r\nfound a b",
+		"gemini://example.org:1965/slow":         "44 5\r\n",
+		"gemini://example.org:1965/large":        "20 application/octet-stream\r\n0123456789",
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		setup  func(f *fetcher)
+		code   int
+		stdout string
+		stderr string
+		err    bool
+	}{
+		{"success", "/", nil, exitOK, "# Hello\n", "20 text/gemini\n", false},
+		{"redirect headers", "/old", nil, exitOK, "# Hello\n", "31 /\n20 text/gemini\n", false},
+		{"header only", "/", func(f *fetcher) { f.headerOnly = true }, exitOK, "", "20 text/gemini\n", false},
+		{"not found", "/missing", nil, 50, "", "51 not found\n", false},
+		{"temporary failure", "/slow", nil, 40, "", "44 5\n", false},
+		{"input without answer", "/search", nil, 10, "", "10 Query\n", false},
+		{"input", "/search", func(f *fetcher) { f.input, f.hasInput = "a b", true }, exitOK, "found a b", "10 Query\n20 text/plain\n", false},
+		{"too large", "/large", func(f *fetcher) { f.client.MaxBodySize = 4 }, exitError, "0123", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, stdout, stderr := newTestFetcher(responses)
+			if test.setup != nil {
+				test.setup(f)
+			}
+			link, _ := gemini.GetFullGeminiLink("example.org" + test.path)
+
+			code, err := f.fetch(link)
+			if code != test.code || (err != nil) != test.err {
+				t.Fatalf("unexpected result: %d, %v", code, err)
+			}
+			if stdout.String() != test.stdout {
+				t.Errorf("stdout: %q", stdout.String())
+			}
+			if test.stderr != "" && stderr.String() != test.stderr {
+				t.Errorf("stderr: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestFetchToFiles(t *testing.T) {
+	responses := map[string]string{
+		"gemini://example.org:1965/notes.txt": "20 text/plain\r\nnotes",
+		"gemini://example.org:1965/large":     "20 application/octet-stream\r\n0123456789",
+	}
+	link, _ := gemini.GetFullGeminiLink("example.org/notes.txt")
+
+	f, _, _ := newTestFetcher(responses)
+	f.dir = t.TempDir()
+	if code, err := f.fetch(link); code != exitOK || err != nil {
+		t.Fatalf("fetch to dir: %d, %v", code, err)
+	}
+	if body, err := os.ReadFile(filepath.Join(f.dir, "notes.txt")); err != nil || string(body) != "notes" {
+		t.Errorf("unexpected saved file: %q, %v", body, err)
+	}
+
+	f, stdout, _ := newTestFetcher(responses)
+	f.output = filepath.Join(t.TempDir(), "out")
+	if code, err := f.fetch(link); code != exitOK || err != nil {
+		t.Fatalf("fetch to file: %d, %v", code, err)
+	}
+	if body, err := os.ReadFile(f.output); err != nil || string(body) != "notes" || stdout.Len() != 0 {
+		t.Errorf("unexpected output file: %q, %v", body, err)
+	}
+
+	// failed body leaves no partial file
+	f, _, _ = newTestFetcher(responses)
+	f.client.MaxBodySize = 4
+	f.output = filepath.Join(t.TempDir(), "out")
+	link, _ = gemini.GetFullGeminiLink("example.org/large")
+	if code, err := f.fetch(link); code != exitError || err == nil {
+		t.Fatalf("fetch too large to file: %d, %v", code, err)
+	}
+	if _, err := os.Stat(f.output); !os.IsNotExist(err) {
+		t.Errorf("partial output file left: %v", err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"--unknown", "example.org"},
+		{"-o", "out", "example.org/1", "example.org/2"},
+		{"--max-size", "lots", "example.org"},
+	}
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: expected usage exit code, got %d", args, code)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  byteSize
+		err   bool
+	}{
+		{"4096", 4096, false},
+		{"512K", 512 << 10, false},
+		{"10mb", 10 << 20, false},
+		{"1G", 1 << 30, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"ten", 0, true},
+	}
+	for _, test := range tests {
+		var size byteSize
+		err := size.Set(test.value)
+		if (err != nil) != test.err || size != test.want {
+			t.Errorf("Set(%q) = %d, %v", test.value, size, err)
+		}
+	}
+}
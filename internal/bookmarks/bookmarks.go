@@ -0,0 +1,192 @@
+// Package bookmarks keeps saved pages in a file and renders them as a gemtext page
+package bookmarks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+const (
+	// TagPrefix marks tags in bookmark commands and on the rendered page
+	TagPrefix = "#"
+	// StartPage is a home page setting that opens bookmarks page instead of a capsule
+	StartPage = "bookmarks"
+)
+
+type Bookmark struct {
+	URL   string
+	Title string
+	Tags  []string
+	Added time.Time
+}
+
+// Store keeps bookmarks in a text file, one "added\turl\ttitle\ttags" line per bookmark
+// with tags separated by spaces. The whole file is rewritten on every change
+type Store struct {
+	path      string
+	bookmarks []Bookmark
+	mu        sync.Mutex
+}
+
+// DefaultPath returns bookmarks location in user config dir
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "bookmarks"), nil
+}
+
+// NewStore loads bookmarks from path, missing file means no bookmarks yet
+func NewStore(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("open bookmarks: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("malformed bookmarks line: %q", line)
+		}
+		added, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed bookmark time: %w", err)
+		}
+		bookmark := Bookmark{Added: added, URL: fields[1], Title: fields[2]}
+		if tags := strings.Fields(fields[3]); len(tags) > 0 {
+			bookmark.Tags = tags
+		}
+		store.bookmarks = append(store.bookmarks, bookmark)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan bookmarks: %w", err)
+	}
+	return store, nil
+}
+
+// Add bookmarks link, bookmarking it again replaces title and tags but keeps its number
+func (s *Store) Add(link, title string, tags []string, added time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bookmark := Bookmark{
+		URL:   link,
+		Title: strings.Join(strings.Fields(title), " "),
+		Added: added.UTC().Truncate(time.Second),
+	}
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.Join(strings.Fields(tag), "-"), TagPrefix)
+		if tag != "" && !slices.Contains(bookmark.Tags, tag) {
+			bookmark.Tags = append(bookmark.Tags, tag)
+		}
+	}
+
+	bookmarks := slices.Clone(s.bookmarks)
+	if i := slices.IndexFunc(bookmarks, func(b Bookmark) bool { return b.URL == link }); i >= 0 {
+		bookmark.Added = bookmarks[i].Added
+		bookmarks[i] = bookmark
+	} else {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return s.save(bookmarks)
+}
+
+// Delete removes bookmark with number, starting from 1, as listed by List(tag),
+// so numbers shown on a page filtered by tag delete bookmarks shown there
+func (s *Store) Delete(tag string, number int) error {
+	list := s.List(tag)
+	if number < 1 || number > len(list) {
+		return fmt.Errorf("no bookmark with number %d", number)
+	}
+	link := list[number-1].URL
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(slices.DeleteFunc(slices.Clone(s.bookmarks), func(b Bookmark) bool { return b.URL == link }))
+}
+
+// List returns bookmarks tagged with tag in order they were added, empty tag means all of them
+func (s *Store) List(tag string) []Bookmark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag = strings.TrimPrefix(tag, TagPrefix)
+	var list []Bookmark
+	for _, bookmark := range s.bookmarks {
+		if tag == "" || slices.Contains(bookmark.Tags, tag) {
+			list = append(list, bookmark)
+		}
+	}
+	return list
+}
+
+// Page renders bookmarks tagged with tag as a gemtext document, links keep order of List
+func (s *Store) Page(tag string) gemtext.Document {
+	tag = strings.TrimPrefix(tag, TagPrefix)
+	heading := "Bookmarks"
+	if tag != "" {
+		heading += " tagged " + TagPrefix + tag
+	}
+	doc := gemtext.Document{gemtext.Heading{Level: 1, Text: heading}}
+
+	list := s.List(tag)
+	if len(list) == 0 {
+		return append(doc, gemtext.Text("No bookmarks yet"))
+	}
+
+	for _, bookmark := range list {
+		label := bookmark.Title
+		for _, t := range bookmark.Tags {
+			label += " " + TagPrefix + t
+		}
+		doc = append(doc, gemtext.Link{URL: bookmark.URL, Label: strings.TrimSpace(label)})
+	}
+	return doc
+}
+
+// save writes bookmarks to a temporary file and renames it, so a failed write keeps the old file
+func (s *Store) save(bookmarks []Bookmark) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, bookmark := range bookmarks {
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n",
+			bookmark.Added.Format(time.RFC3339), bookmark.URL, bookmark.Title, strings.Join(bookmark.Tags, " "))
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.bookmarks = bookmarks
+	return nil
+}
@@ -0,0 +1,86 @@
+package bookmarks
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "bookmarks")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := store.Add("gemini://example.org/", "Example\tcapsule", []string{"#misc"}, now); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := store.Add("gemini://other.org/gemlog/", "Gemlog", []string{"blogs", "#misc", "misc"}, now); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	// bookmarking again updates the entry in place
+	if err := store.Add("gemini://example.org/", "Example", nil, now.Add(time.Hour)); err != nil {
+		t.Fatalf("add again: %v", err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	want := []Bookmark{
+		{URL: "gemini://example.org/", Title: "Example", Added: now},
+		{URL: "gemini://other.org/gemlog/", Title: "Gemlog", Tags: []string{"blogs", "misc"}, Added: now},
+	}
+	if got := reloaded.List(""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected bookmarks: %+v", got)
+	}
+	if got := reloaded.List("#misc"); len(got) != 1 || got[0].Title != "Gemlog" {
+		t.Errorf("unexpected tagged bookmarks: %+v", got)
+	}
+
+	if err := reloaded.Delete("", 3); err == nil {
+		t.Errorf("expected error for missing bookmark")
+	}
+	if err := reloaded.Delete("#misc", 2); err == nil {
+		t.Errorf("expected error for number outside of tagged list")
+	}
+	if err := reloaded.Delete("", 1); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := reloaded.List(""); len(got) != 1 || got[0].URL != "gemini://other.org/gemlog/" {
+		t.Errorf("unexpected bookmarks after delete: %+v", got)
+	}
+
+	// numbers of tagged list refer to bookmarks shown there
+	if err := reloaded.Add("gemini://third.org/", "Third", []string{"misc"}, now); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := reloaded.Delete("#misc", 2); err != nil {
+		t.Fatalf("delete tagged: %v", err)
+	}
+	if got := reloaded.List(""); len(got) != 1 || got[0].URL != "gemini://other.org/gemlog/" {
+		t.Errorf("unexpected bookmarks after tagged delete: %+v", got)
+	}
+}
+
+func TestPage(t *testing.T) {
+	store, _ := NewStore(filepath.Join(t.TempDir(), "bookmarks"))
+	if got := store.Page("").String(); got != "# Bookmarks\nNo bookmarks yet\n" {
+		t.Errorf("unexpected empty page: %q", got)
+	}
+
+	_ = store.Add("gemini://example.org/", "Example", nil, time.Now())
+	_ = store.Add("gemini://other.org/", "", []string{"blogs"}, time.Now())
+
+	want := "# Bookmarks\n=> gemini://example.org/ Example\n=> gemini://other.org/ #blogs\n"
+	if got := store.Page("").String(); got != want {
+		t.Errorf("unexpected page: %q", got)
+	}
+	want = "# Bookmarks tagged #blogs\n=> gemini://other.org/ #blogs\n"
+	if got := store.Page("blogs").String(); got != want {
+		t.Errorf("unexpected tagged page: %q", got)
+	}
+}
@@ -0,0 +1,328 @@
+// Package config reads settings shared by client tools from a TOML file.
+// Only the subset used by the file is supported: sections, comments and
+// string, integer and boolean values.
+//
+// Tools use loaded values as defaults of their command line flags,
+// so flags given explicitly take precedence over the file
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/render"
+)
+
+// Config holds settings of all tools, command line flags override them
+type Config struct {
+	Client  Client
+	Crawler Crawler
+	// Timeouts limit requests of client and crawler
+	Timeouts gemini.Timeouts
+	Colors   Colors
+	// Handlers open non-text media types, see download.Handlers
+	Handlers map[string]string
+}
+
+type Client struct {
+	// Home is opened by g, "bookmarks" shows bookmarks
+	Home      string
+	Prompt    string
+	Width     int
+	Pager     bool
+	TUI       bool
+	MaxMB     int
+	Downloads string
+	// Cache keeps visited pages in crawler layout, empty means pagedb.DefaultCacheDir
+	Cache    string
+	CacheTTL time.Duration
+	// Mode is online, offline or hybrid, the latter two read pages from crawler DB and queue missing ones
+	Mode  string
+	DB    string
+	Queue string
+}
+
+type Crawler struct {
+	DB           string
+	Queue        string
+	ErrorLog     string
+	KnownHosts   string
+	ThrottleMS   int
+	RecrawlHours int
+	MaxKB        int
+	Workers      int
+}
+
+// Colors are names like "blue" or "none", see render.ParseColor
+type Colors struct {
+	Link     string
+	Quote    string
+	Heading1 string
+	Heading2 string
+	Heading3 string
+}
+
+// Default returns settings used when there is no config file
+func Default() *Config {
+	return &Config{
+		Client: Client{
+			Home:     "gemini://geminiprotocol.net:1965/",
+			Prompt:   "🔴➡ ",
+			Pager:    true,
+			MaxMB:    32,
+			CacheTTL: time.Hour,
+			Mode:     "online",
+			DB:       "data",
+			Queue:    "queue.txt",
+		},
+		Crawler: Crawler{
+			DB:           "data",
+			Queue:        "queue.txt",
+			ErrorLog:     "error_queue.log",
+			ThrottleMS:   1500,
+			RecrawlHours: 24 * 32,
+			MaxKB:        500,
+			Workers:      4,
+		},
+		Timeouts: gemini.DefaultTimeouts,
+		Colors: Colors{
+			Link:     "blue",
+			Quote:    "dim",
+			Heading1: "red",
+			Heading2: "green",
+			Heading3: "yellow",
+		},
+		Handlers: map[string]string{},
+	}
+}
+
+// DefaultPath returns config location in user config dir
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "config.toml"), nil
+}
+
+// LoadDefault reads config from DefaultPath. Defaults are returned along with the error if it cannot be read,
+// so tools keep working with a broken config
+func LoadDefault() (*Config, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return Default(), err
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		return Default(), err
+	}
+	return config, nil
+}
+
+// Load reads config from path over defaults, missing file means defaults
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("open config: %w", err)
+	}
+	defer file.Close()
+
+	fields := config.fields()
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed section %q", path, number, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, number)
+		}
+		key, err := unquote(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+		}
+		value = strings.TrimSpace(value)
+
+		if section == "handlers" {
+			command, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+			}
+			config.Handlers[strings.ToLower(key)] = command
+			continue
+		}
+
+		set, ok := fields[section+"."+key]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown setting %s in section [%s]", path, number, key, section)
+		}
+		if err := set(value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, number, key, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan config: %w", err)
+	}
+	return config, nil
+}
+
+// fields maps "section.key" to setters of config values
+func (c *Config) fields() map[string]func(string) error {
+	return map[string]func(string) error{
+		"client.home":      stringValue(&c.Client.Home),
+		"client.prompt":    stringValue(&c.Client.Prompt),
+		"client.width":     intValue(&c.Client.Width),
+		"client.pager":     boolValue(&c.Client.Pager),
+		"client.tui":       boolValue(&c.Client.TUI),
+		"client.max_mb":    intValue(&c.Client.MaxMB),
+		"client.downloads": stringValue(&c.Client.Downloads),
+		"client.cache":     stringValue(&c.Client.Cache),
+		"client.cache_ttl": durationValue(&c.Client.CacheTTL),
+		"client.mode":      stringValue(&c.Client.Mode),
+		"client.db":        stringValue(&c.Client.DB),
+		"client.queue":     stringValue(&c.Client.Queue),
+
+		"crawler.db":            stringValue(&c.Crawler.DB),
+		"crawler.queue":         stringValue(&c.Crawler.Queue),
+		"crawler.error_log":     stringValue(&c.Crawler.ErrorLog),
+		"crawler.known_hosts":   stringValue(&c.Crawler.KnownHosts),
+		"crawler.throttle_ms":   intValue(&c.Crawler.ThrottleMS),
+		"crawler.recrawl_hours": intValue(&c.Crawler.RecrawlHours),
+		"crawler.max_kb":        intValue(&c.Crawler.MaxKB),
+		"crawler.workers":       intValue(&c.Crawler.Workers),
+
+		"timeouts.dial":      durationValue(&c.Timeouts.Dial),
+		"timeouts.handshake": durationValue(&c.Timeouts.Handshake),
+		"timeouts.header":    durationValue(&c.Timeouts.Header),
+		"timeouts.body":      durationValue(&c.Timeouts.Body),
+
+		"colors.link":     stringValue(&c.Colors.Link),
+		"colors.quote":    stringValue(&c.Colors.Quote),
+		"colors.heading1": stringValue(&c.Colors.Heading1),
+		"colors.heading2": stringValue(&c.Colors.Heading2),
+		"colors.heading3": stringValue(&c.Colors.Heading3),
+	}
+}
+
+// Theme converts color names to renderer theme
+func (c Colors) Theme() (render.Theme, error) {
+	var theme render.Theme
+	names := []string{c.Link, c.Quote, c.Heading1, c.Heading2, c.Heading3}
+	targets := []*string{&theme.Link, &theme.Quote, &theme.Headings[0], &theme.Headings[1], &theme.Headings[2]}
+	for i, name := range names {
+		color, err := render.ParseColor(name)
+		if err != nil {
+			return render.DefaultTheme, err
+		}
+		*targets[i] = color
+	}
+	return theme, nil
+}
+
+func stringValue(target *string) func(string) error {
+	return func(value string) error {
+		s, err := unquote(value)
+		if err != nil {
+			return err
+		}
+		*target = s
+		return nil
+	}
+}
+
+func intValue(target *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected integer, got %s", value)
+		}
+		*target = n
+		return nil
+	}
+}
+
+func boolValue(target *bool) func(string) error {
+	return func(value string) error {
+		switch value {
+		case "true":
+			*target = true
+		case "false":
+			*target = false
+		default:
+			return fmt.Errorf("expected true or false, got %s", value)
+		}
+		return nil
+	}
+}
+
+// durationValue accepts strings like "10s", zero disables the timeout
+func durationValue(target *time.Duration) func(string) error {
+	return func(value string) error {
+		s, err := unquote(value)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*target = d
+		return nil
+	}
+}
+
+// unquote returns bare keys as is and decodes basic "..." and literal '...' strings
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1], nil
+	case strings.ContainsAny(s, "\"' \t"):
+		return "", fmt.Errorf("malformed string %s", s)
+	}
+	return s, nil
+}
+
+// stripComment cuts "#" comment outside of quoted strings
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
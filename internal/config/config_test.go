@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/render"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+# start from bookmarks
+[client]
+home = "bookmarks"
+prompt = "gemini# " # comment after a value
+pager = false
+max_mb = 8
+cache_ttl = "30m"
+mode = "hybrid"
+
+[crawler]
+workers = 16
+db = '/var/lib/gemini'
+
+[timeouts]
+header = "30s"
+
+[colors]
+link = "cyan"
+heading1 = "38;5;208"
+
+[handlers]
+"image/*" = "feh -F"
+`)
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want := Default()
+	want.Client.Home = "bookmarks"
+	want.Client.Prompt = "gemini# "
+	want.Client.Pager = false
+	want.Client.MaxMB = 8
+	want.Client.CacheTTL = 30 * time.Minute
+	want.Client.Mode = "hybrid"
+	want.Crawler.Workers = 16
+	want.Crawler.DB = "/var/lib/gemini"
+	want.Timeouts.Header = 30 * time.Second
+	want.Colors.Link = "cyan"
+	want.Colors.Heading1 = "38;5;208"
+	want.Handlers["image/*"] = "feh -F"
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("unexpected config:\n%+v\nwant\n%+v", config, want)
+	}
+
+	theme, err := config.Colors.Theme()
+	if err != nil {
+		t.Fatalf("theme: %v", err)
+	}
+	if theme.Link != "\033[36m" || theme.Headings[0] != "\033[38;5;208m" || theme.Quote != render.DefaultTheme.Quote {
+		t.Errorf("unexpected theme: %q", theme)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	config, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil || !reflect.DeepEqual(config, Default()) {
+		t.Fatalf("missing config should mean defaults: %+v %v", config, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"[client]\nunknown = 1\n", "unknown setting"},
+		{"[client]\nwidth = wide\n", "expected integer"},
+		{"[client]\npager = yes\n", "expected true or false"},
+		{"[timeouts]\ndial = \"soon\"\n", "dial"},
+		{"[client\n", "malformed section"},
+		{"home\n", "expected key = value"},
+		{"[client]\nhome = a b\n", "malformed string"},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%q) error = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+
+	colors := Default().Colors
+	colors.Link = "sparkly"
+	if _, err := colors.Theme(); err == nil {
+		t.Errorf("expected error for unknown color")
+	}
+}
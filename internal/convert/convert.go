@@ -0,0 +1,51 @@
+// Package convert turns gemtext documents into HTML, CommonMark Markdown and plain text,
+// and Markdown back into gemtext
+package convert
+
+import (
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// Options control conversion of gemtext documents, zero value keeps links and uses defaults
+type Options struct {
+	// Link rewrites link targets, nil keeps them as is. See RewriteLinks
+	Link func(target string) string
+	// Title of HTML page, empty means document title
+	Title string
+	// Lang is language of HTML page, e.g. lang parameter of the page media type
+	Lang string
+	// Template renders HTML page from Page, nil means DefaultTemplate
+	Template *template.Template
+	// Width wraps plain text, 0 disables wrapping
+	Width int
+}
+
+func (o Options) link(target string) string {
+	if o.Link == nil {
+		return target
+	}
+	return o.Link(target)
+}
+
+// RewriteLinks returns link rewriter for pages published outside of Geminispace:
+// relative links to .gmi files get ext instead if it is set, e.g. ".html",
+// absolute gemini links are prefixed with proxy if it is set, e.g. "https://proxy.example/gemini/"
+func RewriteLinks(ext, proxy string) func(string) string {
+	return func(target string) string {
+		link, err := url.Parse(target)
+		if err != nil {
+			return target
+		}
+
+		switch {
+		case link.Scheme == "" && link.Host == "" && ext != "" && strings.HasSuffix(link.Path, ".gmi"):
+			link.Path = strings.TrimSuffix(link.Path, ".gmi") + ext
+			return link.String()
+		case link.Scheme == "gemini" && proxy != "":
+			return proxy + strings.TrimPrefix(target, link.Scheme+"://")
+		}
+		return target
+	}
+}
@@ -0,0 +1,204 @@
+package convert
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+const page = "# Notes & <news>\n" +
+	"Hello *world*\n" +
+	"=> notes.gmi Notes\n" +
+	"=> gemini://example.org/ Example\n" +
+	"* one\n" +
+	"* two\n" +
+	"> quote a\n" +
+	"> quote b\n" +
+	"```ascii art\n" +
+	" ``` x\n" +
+	"```\n" +
+	"1. not a list\n" +
+	"=> javascript:alert(1) bad"
+
+func TestHTML(t *testing.T) {
+	doc := gemtext.ParseString(page)
+	got, err := HTML(doc, Options{Link: RewriteLinks(".html", "https://proxy.example/gemini/"), Lang: "en"})
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+
+	want := `<h1>Notes &amp; &lt;news&gt;</h1>
+<p>Hello *world*</p>
+<ul class="links">
+<li><a href="notes.html">Notes</a></li>
+<li><a href="https://proxy.example/gemini/example.org/">Example</a></li>
+</ul>
+<ul>
+<li>one</li>
+<li>two</li>
+</ul>
+<blockquote>
+<p>quote a</p>
+<p>quote b</p>
+</blockquote>
+<pre title="ascii art" aria-label="ascii art"> ` + "```" + ` x
+</pre>
+<p>1. not a list</p>
+<ul class="links">
+<li>bad</li>
+</ul>
+`
+	if !strings.Contains(got, "<body>\n"+want+"</body>") {
+		t.Fatalf("unexpected body:\n%s", got)
+	}
+	if !strings.Contains(got, `<html lang="en">`) || !strings.Contains(got, "<title>Notes &amp; &lt;news&gt;</title>") {
+		t.Fatalf("unexpected page:\n%s", got)
+	}
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("custom").Parse("{{.Title}}|{{.Body}}"))
+	got, err := HTML(gemtext.ParseString("text"), Options{Template: tmpl, Title: "Custom"})
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	if got != "Custom|<p>text</p>\n" {
+		t.Fatalf("unexpected page: %q", got)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	want := "# Notes \\& \\<news>\n\n" +
+		"Hello \\*world\\*\n\n" +
+		"* [Notes](notes.gmi)\n" +
+		"* [Example](gemini://example.org/)\n\n" +
+		"- one\n" +
+		"- two\n\n" +
+		"> quote a\n>\n> quote b\n\n" +
+		"````ascii art\n ``` x\n````\n\n" +
+		"1\\. not a list\n\n" +
+		"[bad](<javascript:alert(1)>)\n"
+	if got := Markdown(gemtext.ParseString(page), Options{}); got != want {
+		t.Fatalf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	doc := gemtext.ParseString("# Title\nsome words to wrap\n=> gemini://example.org/ a link label\n> quoted words here\n```\n  keep   as is\n```")
+	want := "Title\n=====\n" +
+		"some words\nto wrap\n" +
+		"[1] a link\n    label\n" +
+		"> quoted\n> words here\n" +
+		"  keep   as is\n" +
+		"\nLinks:\n[1] gemini://example.org/\n"
+	if got := Text(doc, Options{Width: 12}); got != want {
+		t.Fatalf("unexpected text:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFromMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "headings",
+			markdown: "# Title\n\nSetext\n------\n\n#### Deep ###",
+			want:     "# Title\n\n## Setext\n\n### Deep",
+		},
+		{
+			name:     "paragraph links follow it in order",
+			markdown: "Some **bold** text with a [link](gemini://a.org/ \"title\")\nand ![image](i.png).",
+			want:     "Some bold text with a link and image.\n=> gemini://a.org/ link\n=> i.png image",
+		},
+		{
+			name:     "link only paragraph",
+			markdown: "[Only link](b.gmi)\n\n[one](1.gmi) | [two](2.gmi)",
+			want:     "=> b.gmi Only link\n\n=> 1.gmi one\n=> 2.gmi two",
+		},
+		{
+			name:     "list links are grouped after the list",
+			markdown: "- item [one](1.gmi)\n  continued\n- item two\n\n1. first\n\nafter",
+			want:     "* item one continued\n* item two\n1. first\n=> 1.gmi one\n\nafter",
+		},
+		{
+			name:     "lists of links are link lines",
+			markdown: "- [a](a.gmi)\n- <gemini://b.org/>",
+			want:     "=> a.gmi a\n=> gemini://b.org/",
+		},
+		{
+			name:     "quote",
+			markdown: "> quoted [q](q.gmi)\n> more",
+			want:     "> quoted q\n> more\n=> q.gmi q",
+		},
+		{
+			name:     "code keeps alt text",
+			markdown: "~~~go\n# not a heading\n```\n~~~",
+			want:     "```go\n# not a heading\n```\n```",
+		},
+		{
+			name:     "indented code",
+			markdown: "text\n    not code\n\n    code\n\n      more\n\nafter",
+			want:     "text not code\n\n```\ncode\n\n  more\n```\n\nafter",
+		},
+		{
+			name:     "table",
+			markdown: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:     "```table\n| a | b |\n|---|---|\n| 1 | 2 |\n```",
+		},
+		{
+			name:     "references and autolinks",
+			markdown: "ref [label][r], [r] and <gemini://auto.org/> [unknown]\n\n[r]: gemini://ref.org/ \"title\"",
+			want:     "ref label, r and gemini://auto.org/ [unknown]\n=> gemini://ref.org/ label\n=> gemini://ref.org/ r\n=> gemini://auto.org/",
+		},
+		{
+			name:     "escapes",
+			markdown: "\\[not link\\](x) \\*stars\\*",
+			want:     "[not link](x) *stars*",
+		},
+	}
+	for _, test := range tests {
+		doc, err := FromMarkdown(strings.NewReader(test.markdown))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := doc.String(); got != test.want+"\n" {
+			t.Errorf("%s:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+// gemtext converted to Markdown and back keeps its lines
+func TestMarkdownRoundTrip(t *testing.T) {
+	source := "# Title\n\nHello *world* [1]\n\n=> notes.gmi Notes\n=> gemini://example.org/ Example\n\n* one\n* two\n\n> quote\n\n```alt\n ``` code\n```\n"
+	md := Markdown(gemtext.ParseString(source), Options{})
+	doc, err := FromMarkdown(strings.NewReader(md))
+	if err != nil {
+		t.Fatalf("from markdown: %v", err)
+	}
+	if got := doc.String(); got != source {
+		t.Fatalf("round trip:\n%s\nwant:\n%s\nmarkdown:\n%s", got, source, md)
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	rewrite := RewriteLinks(".html", "https://proxy.example/")
+	tests := map[string]string{
+		"notes.gmi":                   "notes.html",
+		"/gemlog/post.gmi#part":       "/gemlog/post.html#part",
+		"gemini://example.org/a.gmi":  "https://proxy.example/example.org/a.gmi",
+		"https://example.org/doc.gmi": "https://example.org/doc.gmi",
+		"image.png":                   "image.png",
+	}
+	for target, want := range tests {
+		if got := rewrite(target); got != want {
+			t.Errorf("rewrite(%q) = %q, want %q", target, got, want)
+		}
+	}
+	if got := RewriteLinks("", "")("gemini://example.org/a.gmi"); got != "gemini://example.org/a.gmi" {
+		t.Errorf("links changed without ext and proxy: %q", got)
+	}
+}
@@ -0,0 +1,363 @@
+package convert
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+var (
+	fenceRe     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*(.*)$")
+	headingRe   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	setextRe    = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	breakRe     = regexp.MustCompile(`^ {0,3}([-*_])(?:\s*[-*_]){2,}\s*$`)
+	quoteRe     = regexp.MustCompile(`^ {0,3}>\s?(.*)$`)
+	bulletRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedRe   = regexp.MustCompile(`^\s*(\d{1,9})[.)]\s+(.*)$`)
+	tableRe     = regexp.MustCompile(`^\s*\|`)
+	referenceRe = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*<?([^\s>]+)>?(?:\s+.*)?$`)
+
+	// linkRe matches inline links and images, reference links and autolinks, see markdownReader.link
+	linkRe = regexp.MustCompile(`(!?)\[([^\]]*)\](?:\(\s*<?([^\s()<>]+)>?(?:\s+"[^"]*")?\s*\)|\[([^\]]*)\])?` +
+		`|<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]+)>`)
+	strongRe    = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	emphasisRe  = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	escapedRe   = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+	protectedRe = regexp.MustCompile(`[\x{E000}-\x{E07F}]`)
+)
+
+// FromMarkdown converts Markdown to gemtext: headings deeper than three levels become level three,
+// fenced code keeps its info string as alt text, indented code and tables are preformatted.
+// Links and images are moved to link lines after the paragraph, list or quote they appear in,
+// paragraphs made of links only are replaced by link lines, emphasis markers are dropped
+func FromMarkdown(r io.Reader) (gemtext.Document, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	m := &markdownReader{refs: map[string]string{}, lastItem: -1}
+	var body []string
+	for _, line := range lines {
+		if match := referenceRe.FindStringSubmatch(line); match != nil {
+			m.refs[strings.ToLower(match[1])] = match[2]
+			continue
+		}
+		body = append(body, line)
+	}
+	m.read(body)
+	return m.doc, nil
+}
+
+// markdownReader builds gemtext document block by block
+type markdownReader struct {
+	doc  gemtext.Document
+	refs map[string]string
+
+	// paragraph lines are joined once paragraph ends
+	paragraph []string
+	// pending links of list or quote are written once it ends, so it is not split by them
+	pending     []gemtext.Link
+	pendingKind string
+	// lastItem is index of list item which indented lines continue, -1 if there is none
+	lastItem int
+}
+
+func (m *markdownReader) read(lines []string) {
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if match := fenceRe.FindStringSubmatch(line); match != nil {
+			m.endParagraph()
+			block := gemtext.Preformatted{Alt: strings.TrimSpace(match[2])}
+			for i++; i < len(lines); i++ {
+				if closing := strings.TrimSpace(lines[i]); strings.HasPrefix(closing, match[1]) &&
+					strings.Trim(closing, match[1][:1]) == "" {
+					break
+				}
+				block.Lines = append(block.Lines, lines[i])
+			}
+			m.emit("", block, nil)
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			m.endParagraph()
+			m.lastItem = -1
+			if next := m.kindAt(lines, i+1); next != m.pendingKind {
+				m.flushLinks()
+			}
+			if m.pendingKind == "" && len(m.doc) > 0 && m.doc[len(m.doc)-1] != gemtext.Text("") {
+				m.doc = append(m.doc, gemtext.Text(""))
+			}
+			continue
+		}
+
+		if match := setextRe.FindStringSubmatch(line); match != nil && len(m.paragraph) > 0 {
+			level := 1
+			if match[1][0] == '-' {
+				level = 2
+			}
+			text, links := m.inline(strings.Join(m.paragraph, " "))
+			m.paragraph = nil
+			m.emit("", gemtext.Heading{Level: level, Text: text}, links)
+			continue
+		}
+
+		if m.lastItem >= 0 && len(m.paragraph) == 0 && startsWithSpace(line) && m.kind(line) == "" {
+			text, links := m.inline(strings.TrimSpace(line))
+			switch item := m.doc[m.lastItem].(type) {
+			case gemtext.ListItem:
+				m.doc[m.lastItem] = item + gemtext.ListItem(" "+text)
+			case gemtext.Text:
+				m.doc[m.lastItem] = item + gemtext.Text(" "+text)
+			}
+			m.pending = append(m.pending, links...)
+			continue
+		}
+
+		if len(m.paragraph) == 0 && isIndentedCode(line) {
+			block := gemtext.Preformatted{}
+			for ; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) != "" && !isIndentedCode(lines[i]) {
+					break
+				}
+				block.Lines = append(block.Lines, strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "    "))
+			}
+			// blank lines after the block are not part of it
+			for len(block.Lines) > 0 && strings.TrimSpace(block.Lines[len(block.Lines)-1]) == "" {
+				block.Lines = block.Lines[:len(block.Lines)-1]
+				i--
+			}
+			i--
+			m.emit("", block, nil)
+			continue
+		}
+
+		switch {
+		case breakRe.MatchString(line):
+			m.endParagraph()
+
+		case headingRe.MatchString(line):
+			m.endParagraph()
+			match := headingRe.FindStringSubmatch(line)
+			text, links := m.inline(match[2])
+			m.emit("", gemtext.Heading{Level: min(len(match[1]), 3), Text: text}, links)
+
+		case quoteRe.MatchString(line):
+			m.endParagraph()
+			text, links := m.inline(quoteRe.FindStringSubmatch(line)[1])
+			if text != "" {
+				m.emit("quote", gemtext.Quote(text), links)
+			}
+
+		case bulletRe.MatchString(line):
+			m.endParagraph()
+			item := bulletRe.FindStringSubmatch(line)[1]
+			text, links := m.inline(item)
+			if m.linksOnly(item, links) {
+				// lists of links are how link lines look like in Markdown
+				m.emitLinks(links)
+				continue
+			}
+			m.emit("list", gemtext.ListItem(text), links)
+			m.lastItem = len(m.doc) - 1
+
+		case orderedRe.MatchString(line):
+			// gemtext has no numbered lists, numbers are kept as text
+			m.endParagraph()
+			match := orderedRe.FindStringSubmatch(line)
+			text, links := m.inline(match[2])
+			m.emit("list", gemtext.Text(match[1]+". "+text), links)
+			m.lastItem = len(m.doc) - 1
+
+		case tableRe.MatchString(line) && len(m.paragraph) == 0:
+			block := gemtext.Preformatted{Alt: "table"}
+			for ; i < len(lines) && tableRe.MatchString(lines[i]); i++ {
+				block.Lines = append(block.Lines, strings.TrimSpace(lines[i]))
+			}
+			i--
+			m.emit("", block, nil)
+
+		default:
+			m.paragraph = append(m.paragraph, strings.TrimSpace(line))
+		}
+	}
+
+	m.endParagraph()
+	m.flushLinks()
+	for len(m.doc) > 0 && m.doc[len(m.doc)-1] == gemtext.Text("") {
+		m.doc = m.doc[:len(m.doc)-1]
+	}
+}
+
+// kind tells whether line belongs to a list, a quote or neither of them
+func (m *markdownReader) kind(line string) string {
+	switch {
+	case quoteRe.MatchString(line):
+		return "quote"
+	case breakRe.MatchString(line):
+		return "break"
+	case bulletRe.MatchString(line), orderedRe.MatchString(line):
+		return "list"
+	}
+	return ""
+}
+
+// kindAt is kind of the first non-blank line starting from i, loose lists continue after blank lines
+func (m *markdownReader) kindAt(lines []string, i int) string {
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			return m.kind(lines[i])
+		}
+	}
+	return ""
+}
+
+// emit adds line with its links, links of lists and quotes are held until they end
+func (m *markdownReader) emit(kind string, line gemtext.Line, links []gemtext.Link) {
+	if kind != m.pendingKind {
+		m.flushLinks()
+	}
+	m.lastItem = -1
+	m.doc = append(m.doc, line)
+	if kind == "" {
+		m.addLinks(links)
+		return
+	}
+	m.pending = append(m.pending, links...)
+	m.pendingKind = kind
+}
+
+func (m *markdownReader) flushLinks() {
+	m.addLinks(m.pending)
+	m.pending, m.pendingKind = nil, ""
+}
+
+func (m *markdownReader) addLinks(links []gemtext.Link) {
+	for _, link := range links {
+		m.doc = append(m.doc, link)
+	}
+}
+
+// endParagraph writes paragraph as a single text line followed by its links,
+// paragraph with nothing but links is written as link lines only, like list items of links
+func (m *markdownReader) endParagraph() {
+	if len(m.paragraph) == 0 {
+		return
+	}
+	joined := strings.Join(m.paragraph, " ")
+	m.paragraph = nil
+
+	text, links := m.inline(joined)
+	if m.linksOnly(joined, links) {
+		m.emitLinks(links)
+		return
+	}
+	m.emit("", gemtext.Text(text), links)
+}
+
+// linksOnly tells whether text has nothing but links and punctuation between them
+func (m *markdownReader) linksOnly(text string, links []gemtext.Link) bool {
+	return len(links) > 0 && !hasWords(m.withoutLinks(text))
+}
+
+// emitLinks adds link lines standing for a whole block
+func (m *markdownReader) emitLinks(links []gemtext.Link) {
+	m.flushLinks()
+	m.lastItem = -1
+	m.addLinks(links)
+}
+
+// inline drops Markdown inline syntax from text and returns links found in it
+func (m *markdownReader) inline(text string) (string, []gemtext.Link) {
+	var links []gemtext.Link
+	text = linkRe.ReplaceAllStringFunc(protect(text), func(s string) string {
+		label, link, ok := m.link(s)
+		if ok {
+			links = append(links, link)
+		}
+		return label
+	})
+	return plain(text), links
+}
+
+// withoutLinks is paragraph text without links, images and autolinks
+func (m *markdownReader) withoutLinks(text string) string {
+	return linkRe.ReplaceAllStringFunc(protect(text), func(s string) string {
+		if _, _, ok := m.link(s); ok {
+			return ""
+		}
+		return s
+	})
+}
+
+// link parses linkRe match and returns text shown instead of it,
+// brackets which are not links, like unknown references, are kept as text
+func (m *markdownReader) link(s string) (string, gemtext.Link, bool) {
+	match := linkRe.FindStringSubmatch(s)
+	label, target, key, auto := match[2], match[3], match[4], match[5]
+
+	switch {
+	case auto != "":
+		target = restore(auto)
+		return strings.TrimPrefix(target, "mailto:"), gemtext.Link{URL: target}, true
+
+	case target != "":
+		return label, gemtext.Link{URL: restore(target), Label: plain(label)}, true
+	}
+
+	if key == "" {
+		key = label
+	}
+	target, ok := m.refs[strings.ToLower(restore(key))]
+	if !ok {
+		return s, gemtext.Link{}, false
+	}
+	return label, gemtext.Link{URL: target, Label: plain(label)}, true
+}
+
+// plain drops emphasis and code markers and restores escaped characters
+func plain(text string) string {
+	text = strongRe.ReplaceAllString(text, "$2")
+	text = emphasisRe.ReplaceAllString(text, "$1")
+	text = strings.ReplaceAll(text, "`", "")
+	return strings.TrimSpace(restore(text))
+}
+
+// protect replaces backslash escaped punctuation with private use runes, so it is not read as syntax
+func protect(text string) string {
+	return escapedRe.ReplaceAllStringFunc(text, func(s string) string {
+		return string(rune(0xE000 + int(s[1])))
+	})
+}
+
+func restore(text string) string {
+	return protectedRe.ReplaceAllStringFunc(text, func(s string) string {
+		return string(rune([]rune(s)[0] - 0xE000))
+	})
+}
+
+func hasWords(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// isIndentedCode tells whether line is indented enough to be a code block outside of paragraphs and lists
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+func startsWithSpace(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
@@ -0,0 +1,137 @@
+package convert
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+// DefaultTemplate is HTML page used when Options.Template is nil
+const DefaultTemplate = `<!DOCTYPE html>
+<html{{if .Lang}} lang="{{.Lang}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{.Body}}</body>
+</html>
+`
+
+var defaultTemplate = template.Must(template.New("page").Parse(DefaultTemplate))
+
+// Page is data of HTML template
+type Page struct {
+	Title string
+	Lang  string
+	// Body is converted document
+	Body template.HTML
+}
+
+// HTML converts document to a whole HTML page rendered with the template of opts
+func HTML(doc gemtext.Document, opts Options) (string, error) {
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+	title := opts.Title
+	if title == "" {
+		title = doc.Title()
+	}
+
+	var b strings.Builder
+	page := Page{Title: title, Lang: opts.Lang, Body: template.HTML(HTMLBody(doc, opts))}
+	if err := tmpl.Execute(&b, page); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// htmlGroups are closing tags of elements wrapping consecutive lines of the same kind
+var htmlGroups = map[string]string{
+	"links": "</ul>\n",
+	"list":  "</ul>\n",
+	"quote": "</blockquote>\n",
+}
+
+// HTMLBody converts document to HTML elements without page around them.
+// Consecutive link lines, list items and quotes are grouped into single lists and quotes
+func HTMLBody(doc gemtext.Document, opts Options) string {
+	var b strings.Builder
+	group := ""
+	enter := func(next, open string) {
+		if group == next {
+			return
+		}
+		b.WriteString(htmlGroups[group])
+		b.WriteString(open)
+		group = next
+	}
+
+	for _, line := range doc {
+		switch line := line.(type) {
+		case gemtext.Text:
+			enter("", "")
+			if strings.TrimSpace(string(line)) != "" {
+				b.WriteString("<p>" + html.EscapeString(string(line)) + "</p>\n")
+			}
+
+		case gemtext.Heading:
+			enter("", "")
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", line.Level, html.EscapeString(line.Text), line.Level)
+
+		case gemtext.Link:
+			enter("links", "<ul class=\"links\">\n")
+			b.WriteString("<li>" + htmlLink(line, opts) + "</li>\n")
+
+		case gemtext.ListItem:
+			enter("list", "<ul>\n")
+			b.WriteString("<li>" + html.EscapeString(string(line)) + "</li>\n")
+
+		case gemtext.Quote:
+			enter("quote", "<blockquote>\n")
+			b.WriteString("<p>" + html.EscapeString(string(line)) + "</p>\n")
+
+		case gemtext.Preformatted:
+			enter("", "")
+			b.WriteString("<pre")
+			if line.Alt != "" {
+				alt := html.EscapeString(line.Alt)
+				b.WriteString(` title="` + alt + `" aria-label="` + alt + `"`)
+			}
+			b.WriteString(">")
+			for _, text := range line.Lines {
+				b.WriteString(html.EscapeString(text) + "\n")
+			}
+			b.WriteString("</pre>\n")
+		}
+	}
+	enter("", "")
+	return b.String()
+}
+
+// htmlLink makes anchor of link, links with schemes unsafe in browsers are shown as text
+func htmlLink(link gemtext.Link, opts Options) string {
+	name := html.EscapeString(link.Name())
+	target := opts.link(link.URL)
+	if !safeScheme(target) {
+		return name
+	}
+	return `<a href="` + html.EscapeString(target) + `">` + name + `</a>`
+}
+
+var safeSchemes = map[string]bool{
+	"": true, "gemini": true, "http": true, "https": true, "gopher": true,
+	"finger": true, "spartan": true, "titan": true, "mailto": true, "ftp": true, "irc": true, "news": true,
+}
+
+// safeScheme rejects links like javascript: which would run in browsers of readers
+func safeScheme(target string) bool {
+	link, err := url.Parse(target)
+	return err == nil && safeSchemes[strings.ToLower(link.Scheme)]
+}
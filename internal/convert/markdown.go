@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+// Markdown converts document to CommonMark. Every text line is a paragraph,
+// consecutive link lines become a list and a single link line a paragraph with the link
+func Markdown(doc gemtext.Document, opts Options) string {
+	var blocks []string
+	var group []string
+	kind := ""
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		if kind == "links" && len(group) == 1 {
+			group[0] = strings.TrimPrefix(group[0], "* ")
+		}
+		separator := "\n"
+		if kind == "quote" {
+			// lines of a quote would be joined into a single paragraph otherwise
+			separator = "\n>\n"
+		}
+		blocks = append(blocks, strings.Join(group, separator))
+		group, kind = nil, ""
+	}
+	add := func(next, line string) {
+		if kind != next {
+			flush()
+		}
+		kind = next
+		group = append(group, line)
+	}
+
+	for _, line := range doc {
+		switch line := line.(type) {
+		case gemtext.Text:
+			flush()
+			if text := strings.TrimSpace(string(line)); text != "" {
+				blocks = append(blocks, escapeBlock(text))
+			}
+
+		case gemtext.Heading:
+			flush()
+			text := escapeInline(strings.TrimSpace(line.Text))
+			if strings.HasSuffix(text, "#") {
+				// trailing hashes close ATX headings
+				text = text[:len(text)-1] + `\#`
+			}
+			blocks = append(blocks, strings.Repeat("#", line.Level)+" "+text)
+
+		case gemtext.Link:
+			// links use other bullet than list items, so adjacent lists are not merged
+			add("links", "* "+markdownLink(line, opts))
+
+		case gemtext.ListItem:
+			add("list", "- "+escapeBlock(strings.TrimSpace(string(line))))
+
+		case gemtext.Quote:
+			add("quote", "> "+escapeBlock(strings.TrimSpace(string(line))))
+
+		case gemtext.Preformatted:
+			flush()
+			blocks = append(blocks, codeBlock(line))
+		}
+	}
+	flush()
+
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func markdownLink(link gemtext.Link, opts Options) string {
+	target := opts.link(link.URL)
+	if strings.ContainsAny(target, " ()<>") {
+		target = "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(target) + ">"
+	}
+	return "[" + escapeInline(link.Name()) + "](" + target + ")"
+}
+
+// codeBlock fences lines with more backticks than any line starts with, alt text is the info string
+func codeBlock(block gemtext.Preformatted) string {
+	longest := 0
+	for _, line := range block.Lines {
+		trimmed := strings.TrimLeft(line, " ")
+		longest = max(longest, len(trimmed)-len(strings.TrimLeft(trimmed, "`")))
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+
+	lines := []string{fence + strings.ReplaceAll(strings.TrimSpace(block.Alt), "`", "")}
+	lines = append(lines, block.Lines...)
+	return strings.Join(append(lines, fence), "\n")
+}
+
+var inlineEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, "&", `\&`)
+
+// blockStart matches text which would start a Markdown block other than paragraph
+var blockStart = regexp.MustCompile(`^(?:[#>+=~|-]|\d{1,9}[.)])`)
+
+// escapeInline escapes characters with inline meaning: emphasis, code, links, HTML and entities
+func escapeInline(text string) string {
+	return inlineEscaper.Replace(text)
+}
+
+// escapeBlock escapes text which also starts a line, so it is not read as heading, list or quote
+func escapeBlock(text string) string {
+	text = escapeInline(text)
+	if loc := blockStart.FindStringIndex(text); loc != nil {
+		// the last character is the marker, or the delimiter after numbers
+		at := loc[1] - 1
+		text = text[:at] + `\` + text[at:]
+	}
+	return text
+}
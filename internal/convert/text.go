@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/render"
+)
+
+// Text converts document to plain text wrapped to opts.Width. Links are numbered like in the client
+// and their targets are listed at the end, preformatted blocks are kept as is
+func Text(doc gemtext.Document, opts Options) string {
+	var lines, targets []string
+	for _, line := range doc {
+		switch line := line.(type) {
+		case gemtext.Text:
+			lines = append(lines, wrap(string(line), "", opts.Width)...)
+
+		case gemtext.Heading:
+			wrapped := wrap(line.Text, "", opts.Width)
+			lines = append(lines, wrapped...)
+			if underline := map[int]string{1: "=", 2: "-"}[line.Level]; underline != "" {
+				longest := 0
+				for _, text := range wrapped {
+					longest = max(longest, render.StringWidth(text))
+				}
+				lines = append(lines, strings.Repeat(underline, longest))
+			}
+
+		case gemtext.Link:
+			targets = append(targets, opts.link(line.URL))
+			lines = append(lines, wrap(line.Name(), fmt.Sprintf("[%d] ", len(targets)), opts.Width)...)
+
+		case gemtext.ListItem:
+			lines = append(lines, wrap(string(line), "* ", opts.Width)...)
+
+		case gemtext.Quote:
+			lines = append(lines, wrap(string(line), "> ", opts.Width)...)
+
+		case gemtext.Preformatted:
+			lines = append(lines, line.Lines...)
+		}
+	}
+
+	if len(targets) > 0 {
+		lines = append(lines, "", "Links:")
+		for i, target := range targets {
+			lines = append(lines, fmt.Sprintf("[%d] %s", i+1, target))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// wrap splits text into lines of at most width columns, first line starts with prefix,
+// following ones with spaces of its width, quotes keep their marker on every line
+func wrap(text, prefix string, width int) []string {
+	indent := strings.Repeat(" ", render.StringWidth(prefix))
+	if prefix == "> " {
+		indent = prefix
+	}
+
+	available := width - len(indent)
+	if width <= 0 || available < 1 {
+		available = 0
+	}
+
+	lines := render.Wrap(text, available)
+	for i := range lines {
+		if i == 0 {
+			lines[i] = prefix + lines[i]
+		} else {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return lines
+}
@@ -3,30 +3,39 @@ package crawler
 import (
 	"bufio"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
 )
 
 type Options struct {
-	DBDir         string
-	QueuePath     string
-	ErrorLogPath  string
-	Throttle      time.Duration
-	RecrawlWindow time.Duration
-	MaxResponseKB int
-	Workers       int
+	DBDir        string
+	QueuePath    string
+	ErrorLogPath string
+	// KnownHostsPath keeps pinned certificates, defaults to DBDir/known_hosts
+	KnownHostsPath string
+	Throttle       time.Duration
+	RecrawlWindow  time.Duration
+	MaxResponseKB  int
+	Workers        int
+	// MaxRetries limits how many times a page answered with 44 slow down is retried, defaults to 3
+	MaxRetries int
+	// MaxSlowDown caps delay asked by 44 slow down, pages asking for longer are given up, defaults to 5 minutes
+	MaxSlowDown time.Duration
+	// Timeouts limit every request, zero value means gemini.DefaultTimeouts
+	Timeouts gemini.Timeouts
 }
 
 type Crawler struct {
@@ -34,6 +43,7 @@ type Crawler struct {
 	wg  sync.WaitGroup
 
 	opts    Options
+	client  *gemini.Client
 	seen    map[string]struct{}
 	lastReq map[string]time.Time //TODO replace with Host type or IP address
 
@@ -47,6 +57,9 @@ type Crawler struct {
 }
 
 func New(opts Options, ctx context.Context) *Crawler {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	if opts.DBDir == "" {
 		opts.DBDir = "data"
 	}
@@ -56,6 +69,9 @@ func New(opts Options, ctx context.Context) *Crawler {
 	if opts.ErrorLogPath == "" {
 		opts.ErrorLogPath = "error_queue.log"
 	}
+	if opts.KnownHostsPath == "" {
+		opts.KnownHostsPath = filepath.Join(opts.DBDir, "known_hosts")
+	}
 	if opts.Throttle == 0 {
 		opts.Throttle = 1500 * time.Millisecond
 	}
@@ -68,6 +84,15 @@ func New(opts Options, ctx context.Context) *Crawler {
 	if opts.Workers <= 0 {
 		opts.Workers = 4
 	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.MaxSlowDown <= 0 {
+		opts.MaxSlowDown = 5 * time.Minute
+	}
+	if opts.Timeouts == (gemini.Timeouts{}) {
+		opts.Timeouts = gemini.DefaultTimeouts
+	}
 
 	var workersJobsList []chan Job
 	for i := 0; i < opts.Workers; i++ {
@@ -82,6 +107,7 @@ func New(opts Options, ctx context.Context) *Crawler {
 	return &Crawler{
 		ctx:              ctx,
 		opts:             opts,
+		client:           newClient(opts.Timeouts),
 		seen:             make(map[string]struct{}, 4096),
 		lastReq:          make(map[string]time.Time),
 		jobsCandidates:   make(chan RawJob, 8192),
@@ -90,18 +116,19 @@ func New(opts Options, ctx context.Context) *Crawler {
 	}
 }
 
-const PermissionsFull = 0o755
-const PermissionsNonExecutable = 0o644
-
-type pageMeta struct {
-	URL         string    `json:"url"`
-	LastCrawled time.Time `json:"last_crawled"`
-	Status      string    `json:"status"`
-	MIME        string    `json:"mime"`
-	SizeBytes   int       `json:"size_bytes"`
-	Version     int       `json:"version"`
+// newClient follows redirects within a host only, other hosts are queued to respect throttling
+func newClient(timeouts gemini.Timeouts) *gemini.Client {
+	client := gemini.NewClient()
+	client.Timeouts = timeouts
+	client.CheckRedirect = gemini.SameHostRedirects
+	return client
 }
 
+const PermissionsFull = pagedb.PermissionsFull
+const PermissionsNonExecutable = pagedb.PermissionsNonExecutable
+
+type pageMeta = pagedb.Meta
+
 type RawJob string
 type Host string
 
@@ -111,6 +138,9 @@ type Job struct {
 
 	host string
 	id   string
+
+	// retries counts attempts after slow down responses
+	retries int
 }
 
 // Run processes the queue and continues while new items are added (single worker)
@@ -120,6 +150,12 @@ func (c *Crawler) Run() error {
 		return err
 	}
 
+	knownHosts, err := gemini.NewFileKnownHosts(c.opts.KnownHostsPath)
+	if err != nil {
+		return err
+	}
+	c.client.KnownHosts = knownHosts
+
 	go c.startJobsCandidatesProcessor()
 	go c.processInitialQueue(queue)
 	go c.scheduledPrintWorkersStats()
@@ -189,7 +225,6 @@ func (c *Crawler) processJobCandidate(job RawJob) error {
 	}
 
 	link, canonical, err := c.normalizeURL(jobString)
-	//TODO only get geminiLink? gemini.GetFullGeminiLink
 	if err != nil {
 		//TODO add to ignore list?
 		return fmt.Errorf("error: invalid URL: %s", job)
@@ -218,11 +253,14 @@ func (c *Crawler) processJobCandidate(job RawJob) error {
 	workerNumber := c.findWorkerToDoTheJob(host)
 
 	c.wg.Go(func() {
-		c.workersJobsList[workerNumber] <- Job{
+		select {
+		case c.workersJobsList[workerNumber] <- Job{
 			link:      link,
 			canonical: canonical,
 			host:      host,
 			id:        id,
+		}:
+		case <-c.ctx.Done():
 		}
 	})
 
@@ -242,7 +280,11 @@ func (c *Crawler) processInitialQueue(queue []string) {
 		}
 
 		job := queue[jobNum]
-		c.jobsCandidates <- RawJob(job)
+		select {
+		case c.jobsCandidates <- RawJob(job):
+		case <-c.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -296,7 +338,19 @@ func (c *Crawler) findWorkerToDoTheJob(host string) int {
 }
 
 func (c *Crawler) worker(number int, jobs <-chan Job) {
-	for job := range jobs {
+	for {
+		var job Job
+		select {
+		case <-c.ctx.Done():
+			return
+		case next, ok := <-jobs:
+			if !ok {
+				fmt.Printf("job channel for worker is closed")
+				return
+			}
+			job = next
+		}
+
 		should, err := c.shouldFetch(job)
 		if err != nil {
 			fmt.Printf("error: %s %v\n", job.canonical, err)
@@ -315,12 +369,63 @@ func (c *Crawler) worker(number int, jobs <-chan Job) {
 		fmt.Printf("fetching: %s\n", job.canonical)
 		err, status, length := c.doRequest(job)
 		if err != nil {
+			if c.ctx.Err() != nil {
+				// interrupted fetch says nothing about the page itself
+				return
+			}
+
+			var statusErr *gemini.StatusError
+			if errors.As(err, &statusErr) && statusErr.Code == gemini.CodeSlowDown {
+				switch {
+				case !c.slowDown(job, statusErr.Meta):
+					err = fmt.Errorf("%w, gave up as it is longer than %s", err, c.opts.MaxSlowDown)
+				case c.retryLater(number, job):
+					continue
+				default:
+					err = fmt.Errorf("%w, gave up after %d retries", err, job.retries)
+				}
+			}
+
 			c.logError(job.canonical, err)
 			_ = c.writeErrorMeta(job, status, length)
 		}
 	}
+}
 
-	fmt.Printf("job channel for worker is closed")
+// slowDown postpones next request to the host by the number of seconds in meta, capped by opts.MaxSlowDown.
+// false means the capsule asked to wait longer than that, so the page is not worth retrying
+func (c *Crawler) slowDown(job Job, meta string) bool {
+	seconds, err := strconv.Atoi(strings.TrimSpace(meta))
+	if err != nil || seconds <= 0 {
+		seconds = 60
+	}
+	// compared in seconds, as huge values overflow time.Duration
+	maxSeconds := int(c.opts.MaxSlowDown / time.Second)
+	delay := time.Duration(min(seconds, maxSeconds)) * time.Second
+	fmt.Printf("slowing down %s for %s\n", job.host, delay)
+
+	c.lastReqMu.Lock()
+	defer c.lastReqMu.Unlock()
+	// throttle waits opts.Throttle after this moment
+	c.lastReq[job.host] = time.Now().Add(delay)
+	return seconds <= maxSeconds
+}
+
+// retryLater puts the job back to the same worker queue, false means it was retried too many times.
+// The page stays seen meanwhile, so links to it are not queued again
+func (c *Crawler) retryLater(workerNumber int, job Job) bool {
+	if job.retries >= c.opts.MaxRetries {
+		return false
+	}
+	job.retries++
+
+	c.wg.Go(func() {
+		select {
+		case c.workersJobsList[workerNumber] <- job:
+		case <-c.ctx.Done():
+		}
+	})
+	return true
 }
 
 func (c *Crawler) doRequest(job Job) (error, string, int) {
@@ -330,29 +435,57 @@ func (c *Crawler) doRequest(job Job) (error, string, int) {
 		return err, "job.canonical-error", 0
 	}
 
-	resp, err := gemini.DoRequest(reqURL)
+	resp, err := c.client.Stream(c.ctx, reqURL)
 	if err != nil {
+		// skip capsules whose certificate changed, error log keeps both fingerprints
+		var mismatch *gemini.CertMismatchError
+		if errors.As(err, &mismatch) {
+			return err, "cert-mismatch", 0
+		}
+		// redirects which cannot be followed stay so until the capsule changes them
+		if errors.Is(err, gemini.ErrRedirectLoop) || errors.Is(err, gemini.ErrRedirectRefused) {
+			return err, "redirect-error", 0
+		}
 		return err, "request-error", 0
 	}
 
-	responseLength := len(resp.Body)
+	if resp.Status == gemini.StatusRedirect {
+		return c.processRedirect(job, resp)
+	}
 
 	if resp.Status != gemini.StatusSuccess {
-		err := fmt.Errorf("status %d: %s", resp.Status, resp.Meta)
-		return err, fmt.Sprintf("status-%d", resp.Status), responseLength
+		err := resp.Err()
+		if err == nil {
+			err = fmt.Errorf("status %d: %s", resp.Code, resp.Meta)
+		}
+		return err, fmt.Sprintf("status-%d", resp.Code), 0
 	}
+	defer resp.BodyReader.Close()
 
+	// abort oversized downloads while reading instead of buffering them whole
+	var bodyReader io.Reader = resp.BodyReader
 	textualResponse := strings.Contains(job.canonical, ".gmi") ||
 		strings.Contains(job.canonical, ".txt")
-	if !textualResponse {
-		if max := c.opts.MaxResponseKB; max > 0 && responseLength > max*1024 {
-			err := fmt.Errorf("response too large: %d bytes", responseLength)
-			return err, "too-large", responseLength
-		}
+	if max := c.opts.MaxResponseKB; !textualResponse && max > 0 {
+		bodyReader = gemini.LimitReader(bodyReader, int64(max)*1024)
 	}
 
-	mime := resp.Meta
-	if err := c.savePage(job, mime, resp.Body); err != nil {
+	resp.Body, err = io.ReadAll(bodyReader)
+	responseLength := len(resp.Body)
+	if errors.Is(err, gemini.ErrBodyTooLarge) {
+		err := fmt.Errorf("response too large: over %d bytes", responseLength)
+		return err, "too-large", responseLength
+	}
+	if err != nil {
+		return err, "request-error", responseLength
+	}
+
+	// text is stored as UTF-8, pages in unknown charsets are kept as is
+	if err := decodeBody(resp); err != nil {
+		c.logError(job.canonical, err)
+	}
+
+	if err := c.savePage(job, resp); err != nil {
 		return err, "save-error", responseLength
 	}
 
@@ -362,34 +495,67 @@ func (c *Crawler) doRequest(job Job) (error, string, int) {
 	return nil, "", 0
 }
 
-func (c *Crawler) processBody(job Job, resp *gemini.Response) {
-	// Extract and append links for gemtext only
-	if strings.HasPrefix(strings.ToLower(resp.Meta), gemini.GeminiMediaType) {
-		links := c.extractLinks(job.link, resp.Body)
-		added := 0
-		if len(links) > 0 {
-			toAdd := make([]string, 0, len(links))
-			for _, link := range links {
-				if c.checkSeen(link) {
-					continue
-				}
+// processRedirect records redirect and queues its target as a new job
+func (c *Crawler) processRedirect(job Job, resp *gemini.Response) (error, string, int) {
+	target, err := resp.URL.Parse(strings.TrimSpace(resp.Meta))
+	if err != nil {
+		return fmt.Errorf("invalid redirect: %w", err), "redirect-error", 0
+	}
 
-				toAdd = append(toAdd, link)
-				c.jobsCandidates <- RawJob(link)
-			}
+	_, canonical, err := c.normalizeURL(target.String())
+	if err != nil {
+		return fmt.Errorf("invalid redirect: %w", err), "redirect-error", 0
+	}
 
-			//TODO should only append canonical (non-rejected) urls, this impl looks wonky
-			if len(toAdd) > 0 {
-				c.appendToQueueDedup(toAdd)
-				added = len(toAdd)
-			}
+	if err := c.writeRedirectMeta(job, resp, canonical); err != nil {
+		return err, "save-error", 0
+	}
+
+	fmt.Printf("redirected: %s -> %s\n", job.canonical, canonical)
+	c.addLinks([]string{canonical})
+	return nil, "", 0
+}
+
+func (c *Crawler) processBody(job Job, resp *gemini.Response) {
+	// Extract and append links for gemtext only
+	if mediaType, err := resp.MediaType(); err == nil && mediaType.IsGemtext() {
+		// relative links are resolved against the page we were redirected to
+		base := job.link
+		if resp.URL != nil {
+			base = resp.URL
 		}
+
+		links := c.extractLinks(base, resp.Body)
+		added := c.addLinks(links)
 		if added > 0 {
 			fmt.Printf("discovered %d links (added %d)\n", len(links), added)
 		}
 	}
 }
 
+// addLinks queues links not seen in this run, returns number of queued links
+func (c *Crawler) addLinks(links []string) int {
+	toAdd := make([]string, 0, len(links))
+	for _, link := range links {
+		if c.checkSeen(link) {
+			continue
+		}
+
+		toAdd = append(toAdd, link)
+		select {
+		case c.jobsCandidates <- RawJob(link):
+		case <-c.ctx.Done():
+			return len(toAdd)
+		}
+	}
+
+	//TODO should only append canonical (non-rejected) urls, this impl looks wonky
+	if len(toAdd) > 0 {
+		c.appendToQueueDedup(toAdd)
+	}
+	return len(toAdd)
+}
+
 func (c *Crawler) getQueue(queueFile *os.File) ([]string, error) {
 	queue := make([]string, 0, 1024)
 	scanner := bufio.NewScanner(queueFile)
@@ -409,121 +575,37 @@ func (c *Crawler) getQueue(queueFile *os.File) ([]string, error) {
 	return queue, nil
 }
 
-// normalizeURL ensures gemini scheme, lowercased host, no fragment, non-empty path
+// normalizeURL returns request URL with default port and canonical string of raw link
 func (c *Crawler) normalizeURL(raw string) (*url.URL, string, error) {
-	u, err := url.Parse(strings.TrimSpace(raw))
+	link, err := gemini.NormalizeURL(raw)
 	if err != nil {
 		return nil, "", err
 	}
-
-	if u.Scheme == "" {
-		u.Scheme = "gemini"
-	}
-	if u.Scheme != "gemini" {
-		return nil, "", fmt.Errorf("unsupported scheme: %s", u.Scheme)
-	}
-
-	u.Fragment = ""
-	u.Host = strings.ToLower(u.Host)
-	if u.Path == "" {
-		u.Path = "/"
-	}
-
-	// Build canonical without default port
-	canon := canonicalString(u)
-	return u, canon, nil
-}
-
-func canonicalString(u *url.URL) string {
-	// Strip default port 1965
-	host := u.Host
-	if strings.Contains(host, ":") {
-		// If port is 1965, drop it
-		if h, p, ok := strings.Cut(host, ":"); ok && (p == gemini.Port) {
-			host = h
-		}
-	}
-	// Rebuild without fragment (already cleared)
-	var b strings.Builder
-	b.WriteString("gemini://")
-	b.WriteString(host)
-	if u.Path == "" {
-		b.WriteString("/")
-	} else {
-		b.WriteString(u.Path)
-	}
-	if u.RawQuery != "" {
-		b.WriteString("?")
-		b.WriteString(u.RawQuery)
-	}
-	return b.String()
+	return gemini.WithDefaultPort(link), link.String(), nil
 }
 
 func pageID(u *url.URL) (host, id string) {
-	host = strings.ToLower(u.Host)
-	// Drop default port in host dir name
-	if h, p, ok := strings.Cut(host, ":"); ok && (p == gemini.Port) {
-		host = h
-	}
-	canonicalLink := canonicalString(u)
-
-	hashBytes := sha256.Sum256([]byte(canonicalLink))
-	hash := hex.EncodeToString(hashBytes[:])
-
-	slug := slugFromPath(u.Path)
-
-	id = fmt.Sprintf("%s__%s", slug, hash)
-	return host, id
-}
-
-var slugRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
-
-func slugFromPath(p string) string {
-	if p == "" || p == "/" {
-		return "root"
-	}
-
-	parts := strings.Split(strings.TrimSuffix(p, "/"), "/")
-	last := parts[len(parts)-1]
-	last = slugRe.ReplaceAllString(last, "-")
-	if len(last) > 80 {
-		last = last[:80]
-	}
-
-	if last == "" || last == "-" {
-		return "page"
-	}
-	return last
+	return pagedb.PageID(u)
 }
 
-func (c *Crawler) hostDir(host string) string {
-	return filepath.Join(c.opts.DBDir, host)
-}
-
-func (c *Crawler) pagesDir(host string) string {
-	return filepath.Join(c.hostDir(host), "pages")
+func (c *Crawler) db() *pagedb.DB {
+	return pagedb.New(c.opts.DBDir)
 }
 
 func (c *Crawler) metaPath(host, id string) string {
-	return filepath.Join(c.pagesDir(host), "meta", id+".meta.json")
+	return c.db().MetaPath(host, id)
 }
 
 func (c *Crawler) contentPath(host, id, mime string) (string, error) {
-	ext := ".bin"
-	mimeLower := strings.ToLower(mime)
-	if strings.HasPrefix(mimeLower, gemini.GeminiMediaType) {
-		ext = ".gmi"
-	} else if strings.HasPrefix(mimeLower, "text/") {
-		ext = ".txt"
-	} else if strings.HasPrefix(mimeLower, "image/jpeg") {
-		ext = ".jpg"
-	} else if strings.HasPrefix(mimeLower, "image/png") {
-		ext = ".png"
-	}
-	return filepath.Join(c.pagesDir(host), id+ext), nil
+	return c.db().ContentPath(host, id, mime), nil
 }
 
 func (c *Crawler) shouldFetch(job Job) (bool, error) {
+	// retried jobs were checked before their first attempt
+	if job.retries > 0 {
+		return true, nil
+	}
+
 	//seen in this session
 	if c.checkSeen(job.canonical) {
 		// already processed/queued in this run
@@ -546,6 +628,11 @@ func (c *Crawler) shouldFetch(job Job) (bool, error) {
 		return true, nil // malformed meta, try fetching anew
 	}
 
+	//temporary failures are retried after recrawl window, permanent ones (e.g. 51 not found) never
+	if meta.Status != pagedb.StatusSuccess {
+		return retryableStatus(meta.Status) && time.Since(meta.LastCrawled) >= c.opts.RecrawlWindow, nil
+	}
+
 	//do not recrawl non-gemini files (e.g. images)
 	if !strings.HasPrefix(strings.ToLower(meta.MIME), gemini.GeminiMediaType) {
 		return false, nil
@@ -557,118 +644,101 @@ func (c *Crawler) shouldFetch(job Job) (bool, error) {
 	return true, nil
 }
 
+// retryableStatus reports whether failure stored in page meta may go away by itself,
+// unlisted ones like too-large or redirect-error are permanent
+func retryableStatus(status string) bool {
+	switch status {
+	case "request-error", "cert-mismatch", "save-error":
+		return true
+	}
+	code, err := strconv.Atoi(strings.TrimPrefix(status, "status-"))
+	return err == nil && (code/10 == gemini.StatusTemporaryFailure || code == gemini.CodeRedirectTemporary)
+}
+
 // TODO both IP instead of host?
 func (c *Crawler) throttle(job Job) error {
 	c.lastReqMu.Lock()
-	defer c.lastReqMu.Unlock()
-
 	now := time.Now()
-	if lastRequested, ok := c.lastReq[job.host]; ok {
-		elapsed := now.Sub(lastRequested)
-		if wait := c.opts.Throttle - elapsed; wait > 0 {
-			// Unlock during sleep to avoid blocking other hosts in future concurrency
-			time.Sleep(wait)
-		}
+	next := now
+	if lastRequested, ok := c.lastReq[job.host]; ok && lastRequested.Add(c.opts.Throttle).After(now) {
+		next = lastRequested.Add(c.opts.Throttle)
 	}
+	// the moment is reserved before sleeping, so requests to the host stay apart
+	c.lastReq[job.host] = next
+	c.lastReqMu.Unlock()
 
-	c.lastReq[job.host] = time.Now()
+	// sleeping without the lock keeps other hosts going
+	if wait := next.Sub(now); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
 	return nil
 }
 
-func (c *Crawler) savePage(job Job, mime string, body []byte) error {
-	if err := os.MkdirAll(c.pagesDir(job.host), PermissionsFull); err != nil {
-		return err
-	}
-	contentPath, err := c.contentPath(job.host, job.id, mime)
-	if err != nil {
-		return err
-	}
-	contentPathTemp := contentPath + ".tmp"
-	if err := os.WriteFile(contentPathTemp, body, PermissionsNonExecutable); err != nil {
-		return err
-	}
-	if err := os.Rename(contentPathTemp, contentPath); err != nil {
+func (c *Crawler) savePage(job Job, resp *gemini.Response) error {
+	if err := c.db().WriteContent(job.host, job.id, resp.Meta, resp.Body); err != nil {
 		return err
 	}
 
-	meta := pageMeta{
-		URL:         job.canonical,
-		LastCrawled: time.Now().UTC(),
-		Status:      "success",
-		MIME:        mime,
-		SizeBytes:   len(body),
-		Version:     1,
+	meta := newPageMeta(job, pagedb.StatusSuccess, len(resp.Body))
+	meta.MIME = resp.Meta
+	if mediaType, err := resp.MediaType(); err == nil {
+		meta.Lang = mediaType.Lang()
 	}
-
-	metaBytes, _ := json.MarshalIndent(&meta, "", "  ")
-	metaPath := c.metaPath(job.host, job.id)
-	// ensure meta directory exists
-	if err := os.MkdirAll(filepath.Dir(metaPath), PermissionsFull); err != nil {
-		return err
-	}
-
-	metaPathTemp := metaPath + ".tmp"
-	if err := os.WriteFile(metaPathTemp, metaBytes, PermissionsNonExecutable); err != nil {
-		return err
-	}
-	return os.Rename(metaPathTemp, metaPath)
+	meta.Redirects = pagedb.RedirectChain(resp)
+	return c.writeMeta(job, meta)
 }
 
-// TODO unify with savePage meta section?
-func (c *Crawler) writeErrorMeta(job Job, status string, size int) error {
-	if err := os.MkdirAll(c.pagesDir(job.host), PermissionsFull); err != nil {
+// decodeBody converts text body to UTF-8, rewriting charset in response meta
+func decodeBody(resp *gemini.Response) error {
+	mediaType, err := resp.MediaType()
+	if err != nil {
 		return err
 	}
-	meta := pageMeta{
-		URL:         job.canonical,
-		LastCrawled: time.Now().UTC(),
-		Status:      status,
-		MIME:        "",
-		SizeBytes:   size,
-		Version:     1,
+	if !mediaType.IsText() || gemini.IsUTF8Charset(mediaType.Params["charset"]) {
+		return nil
 	}
 
-	metaBytes, _ := json.MarshalIndent(&meta, "", "  ")
-	metaPath := c.metaPath(job.host, job.id)
-	// ensure meta directory exists
-	if err := os.MkdirAll(filepath.Dir(metaPath), PermissionsFull); err != nil {
+	mediaType, body, err := gemini.DecodeText(mediaType, resp.Body)
+	if err != nil {
 		return err
 	}
+	resp.Meta = mediaType.String()
+	resp.Body = body
+	return nil
+}
 
-	metaPathTemp := metaPath + ".tmp"
-	if err := os.WriteFile(metaPathTemp, metaBytes, PermissionsNonExecutable); err != nil {
-		return err
-	}
-	return os.Rename(metaPathTemp, metaPath)
+func (c *Crawler) writeErrorMeta(job Job, status string, size int) error {
+	return c.writeMeta(job, newPageMeta(job, status, size))
 }
 
-func (c *Crawler) extractLinks(base *url.URL, body []byte) []string {
-	text := string(body)
-	lines := strings.Split(text, "\n")
-	out := make([]string, 0, 16)
+// writeRedirectMeta records redirect which was not followed, e.g. to another host
+func (c *Crawler) writeRedirectMeta(job Job, resp *gemini.Response, target string) error {
+	meta := newPageMeta(job, fmt.Sprintf("status-%d", resp.Code), 0)
+	meta.Redirects = append(pagedb.RedirectChain(resp), target)
+	return c.writeMeta(job, meta)
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "=>") {
-			continue
-		}
+func newPageMeta(job Job, status string, size int) pageMeta {
+	return pagedb.NewMeta(job.canonical, status, size)
+}
 
-		line = strings.TrimSpace(strings.TrimPrefix(line, "=>"))
-		if line == "" {
-			continue
-		}
+func (c *Crawler) writeMeta(job Job, meta pageMeta) error {
+	return c.db().WriteMeta(job.host, job.id, meta)
+}
 
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
+func (c *Crawler) extractLinks(base *url.URL, body []byte) []string {
+	links := gemtext.ParseString(string(body)).Links()
+	out := make([]string, 0, len(links))
 
-		refRaw := fields[0]
-		ref, err := url.Parse(refRaw)
+	for _, link := range links {
+		abs, err := link.Resolve(base)
 		if err != nil {
 			continue
 		}
-		abs := base.ResolveReference(ref)
 		if abs.Scheme == "" {
 			abs.Scheme = "gemini"
 		}
@@ -676,19 +746,12 @@ func (c *Crawler) extractLinks(base *url.URL, body []byte) []string {
 			continue
 		}
 
-		// Normalize host to lowercase to keep canonical form consistent
-		abs.Host = strings.ToLower(abs.Host)
-		abs.Fragment = ""
-		if abs.Path == "" {
-			abs.Path = "/"
-		}
-
-		_, canon, err := c.normalizeURL(abs.String())
+		normalized, err := gemini.Normalize(abs)
 		if err != nil {
 			continue
 		}
 
-		out = append(out, canon)
+		out = append(out, normalized.String())
 	}
 	return out
 }
@@ -702,20 +765,16 @@ func (c *Crawler) addSeen(link string) {
 func (c *Crawler) appendToQueueDedup(urls []string) {
 	c.fileQueueMu.Lock()
 	defer c.fileQueueMu.Unlock()
-	f, err := os.OpenFile(c.opts.QueuePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, PermissionsNonExecutable)
-	if err != nil {
-		return
-	}
-	defer f.Close()
 	// The caller already performed in-run deduplication using c.seen and built the list.
+	canonicals := make([]string, 0, len(urls))
 	for _, u := range urls {
 		_, canonical, err := c.normalizeURL(u)
 		if err != nil {
 			continue
 		}
-
-		_, _ = f.WriteString(canonical + "\n")
+		canonicals = append(canonicals, canonical)
 	}
+	_ = pagedb.AppendQueue(c.opts.QueuePath, canonicals...)
 }
 
 func (c *Crawler) logError(urlStr string, err error) {
@@ -2,7 +2,9 @@ package crawler
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -12,8 +14,71 @@ import (
 	"time"
 
 	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
 )
 
+func TestWorker_GivesUpAfterRetries(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCrawler(t, dir)
+	c.opts.MaxRetries = 1
+	c.client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/busy": "44 1\r\n",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.ctx = ctx
+
+	u, canon, _ := c.normalizeURL("gemini://example.org/busy")
+	host, id := pageID(u)
+	jobs := c.workersJobsList[0]
+	jobs <- Job{link: u, canonical: canon, host: host, id: id}
+	go c.worker(0, jobs)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mb, err := os.ReadFile(c.metaPath(host, id))
+		if err == nil {
+			var m pageMeta
+			if err := json.Unmarshal(mb, &m); err != nil {
+				t.Fatalf("meta json: %v", err)
+			}
+			if m.Status != "status-44" {
+				t.Fatalf("unexpected status: %s", m.Status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("page was not given up: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !c.checkSeen(canon) {
+		t.Fatalf("retried page should stay seen")
+	}
+}
+
+func TestRetryLater_Limit(t *testing.T) {
+	c := newTestCrawler(t, t.TempDir())
+	c.opts.MaxRetries = 2
+	job := Job{canonical: "gemini://example.org/", host: "example.org"}
+
+	if !c.retryLater(0, job) {
+		t.Fatalf("expected first retry")
+	}
+	retried := <-c.workersJobsList[0]
+	if retried.retries != 1 {
+		t.Fatalf("unexpected retries: %d", retried.retries)
+	}
+	if should, err := c.shouldFetch(retried); err != nil || !should {
+		t.Fatalf("retried job should be fetched, got %v %v", should, err)
+	}
+
+	retried.retries = 2
+	if c.retryLater(0, retried) {
+		t.Fatalf("expected no retries over the limit")
+	}
+}
+
 func newTestCrawler(t *testing.T, dir string) *Crawler {
 	t.Helper()
 	opts := Options{
@@ -66,6 +131,30 @@ func TestNormalizeAndCanonicalAndPageID(t *testing.T) {
 	}
 }
 
+func TestPageID_EquivalentURLs(t *testing.T) {
+	c := New(Options{}, nil)
+
+	u1, canon1, _ := c.normalizeURL("gemini://bücher.example/%7euser/./notes")
+	u2, canon2, _ := c.normalizeURL("xn--bcher-kva.example:1965/~user/x/../notes")
+	if canon1 != canon2 || canon1 != "gemini://xn--bcher-kva.example/~user/notes" {
+		t.Fatalf("canonical mismatch: %s vs %s", canon1, canon2)
+	}
+	h1, id1 := pageID(u1)
+	h2, id2 := pageID(u2)
+	if h1 != "xn--bcher-kva.example" || h1 != h2 || id1 != id2 {
+		t.Fatalf("pageID mismatch: %s/%s vs %s/%s", h1, id1, h2, id2)
+	}
+
+	// IPv6 hosts keep their port separate from the address
+	u, canon, err := c.normalizeURL("gemini://[::1]:1965/")
+	if err != nil {
+		t.Fatalf("normalize ipv6: %v", err)
+	}
+	if host, _ := pageID(u); host != "[::1]" || canon != "gemini://[::1]/" {
+		t.Fatalf("unexpected ipv6 host %s, canonical %s", host, canon)
+	}
+}
+
 func TestExtractLinks(t *testing.T) {
 	base, _ := url.Parse("gemini://example.org:1965/dir/index.gmi")
 	body := []byte(strings.Join([]string{
@@ -76,6 +165,10 @@ func TestExtractLinks(t *testing.T) {
 		"=> http://example.com/skip",     // not gemini
 		"=> ?query-only",                 // query on current path
 		"not a link",
+		"=>", // no URL
+		"```",
+		"=> /in-preformatted", // preformatted text is not a link
+		"```",
 	}, "\n"))
 
 	var crawler *Crawler
@@ -111,7 +204,7 @@ func TestShouldFetch_RecrawlWindow(t *testing.T) {
 
 	// Write meta with recent crawl
 	recent := pageMeta{
-		URL:         canonicalString(u),
+		URL:         gemini.CanonicalString(u),
 		LastCrawled: time.Now().UTC(),
 		MIME:        gemini.GeminiMediaType,
 		Status:      "success",
@@ -121,7 +214,7 @@ func TestShouldFetch_RecrawlWindow(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	should, err := c.shouldFetch(Job{u, canon, host, id})
+	should, err := c.shouldFetch(Job{link: u, canonical: canon, host: host, id: id})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -139,7 +232,7 @@ func TestShouldFetch_RecrawlWindow(t *testing.T) {
 
 	//refresh seen map, as this link was already seen
 	c.seen = make(map[string]struct{})
-	should, err = c.shouldFetch(Job{u, canon, host, id})
+	should, err = c.shouldFetch(Job{link: u, canonical: canon, host: host, id: id})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -148,7 +241,7 @@ func TestShouldFetch_RecrawlWindow(t *testing.T) {
 	}
 
 	//now it was seen, shouldn't be fetched
-	should, err = c.shouldFetch(Job{u, canon, host, id})
+	should, err = c.shouldFetch(Job{link: u, canonical: canon, host: host, id: id})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -171,7 +264,8 @@ func TestSavePage_WritesFilesAndMeta(t *testing.T) {
 	host, id := pageID(u)
 	content := []byte("=> /next\n# Title\n")
 	mime := "text/gemini; charset=utf-8"
-	if err := c.savePage(Job{u, canon, host, id}, mime, content); err != nil {
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Meta: mime, Body: content}
+	if err := c.savePage(Job{link: u, canonical: canon, host: host, id: id}, resp); err != nil {
 		t.Fatalf("savePage: %v", err)
 	}
 
@@ -246,8 +340,11 @@ func TestLogError_Format(t *testing.T) {
 	if len(parts) != 3 {
 		t.Fatalf("expected 3 fields, got %d: %q", len(parts), line)
 	}
-	if parts[1] != "gemini://example.org/" {
-		t.Fatalf("url field: %s", parts[1])
+	if parts[0] != "gemini://example.org/" {
+		t.Fatalf("url field: %s", parts[0])
+	}
+	if _, err := time.Parse(time.RFC3339, parts[1]); err != nil {
+		t.Fatalf("time field: %v", err)
 	}
 	if strings.Contains(parts[2], "\n") {
 		t.Fatalf("message not sanitized: %q", parts[2])
@@ -261,7 +358,7 @@ func TestThrottle_Waits(t *testing.T) {
 	host := "example.org"
 	c.lastReq[host] = time.Now()
 	start := time.Now()
-	if err := c.throttle(Job{nil, "", host, ""}); err != nil {
+	if err := c.throttle(Job{host: host}); err != nil {
 		t.Fatalf("throttle: %v", err)
 	}
 	elapsed := time.Since(start)
@@ -269,3 +366,202 @@ func TestThrottle_Waits(t *testing.T) {
 		t.Fatalf("expected ~150ms wait, got %v", elapsed)
 	}
 }
+
+func TestShouldFetch_FailedPages(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCrawler(t, dir)
+
+	u, canon, _ := c.normalizeURL("gemini://example.org/failing")
+	host, id := pageID(u)
+	job := Job{link: u, canonical: canon, host: host, id: id}
+
+	tests := []struct {
+		status string
+		age    time.Duration
+		want   bool
+	}{
+		{"status-51", 100 * time.Hour, false},
+		{"status-41", 100 * time.Hour, true},
+		{"status-41", time.Hour, false},
+		{"request-error", 100 * time.Hour, true},
+		{"too-large", 100 * time.Hour, false},
+		{"redirect-error", 100 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		c.seen = make(map[string]struct{})
+		if err := c.writeErrorMeta(job, tt.status, 0); err != nil {
+			t.Fatal(err)
+		}
+		// backdate the failure
+		meta := pageMeta{URL: canon, LastCrawled: time.Now().UTC().Add(-tt.age), Status: tt.status}
+		mb, _ := jsonMarshalIndent(meta)
+		if err := os.WriteFile(c.metaPath(host, id), mb, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		should, err := c.shouldFetch(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if should != tt.want {
+			t.Errorf("%s after %v: expected shouldFetch=%v", tt.status, tt.age, tt.want)
+		}
+	}
+}
+
+func TestSlowDown_DelaysHost(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCrawler(t, dir)
+
+	job := Job{canonical: "gemini://example.org/", host: "example.org"}
+	c.slowDown(job, "2")
+
+	c.lastReqMu.Lock()
+	next := c.lastReq[job.host]
+	c.lastReqMu.Unlock()
+	if until := time.Until(next); until < time.Second || until > 2*time.Second {
+		t.Fatalf("expected host delayed by ~2s, got %v", until)
+	}
+}
+
+func TestSlowDown_Limit(t *testing.T) {
+	c := newTestCrawler(t, t.TempDir())
+	c.opts.MaxSlowDown = 2 * time.Second
+
+	job := Job{canonical: "gemini://example.org/", host: "example.org"}
+	if c.slowDown(job, "3600") {
+		t.Fatalf("expected slow down over the limit to be refused")
+	}
+	c.lastReqMu.Lock()
+	next := c.lastReq[job.host]
+	c.lastReqMu.Unlock()
+	if until := time.Until(next); until > 2*time.Second {
+		t.Fatalf("expected delay capped at 2s, got %v", until)
+	}
+
+	if c.slowDown(job, "99999999999999999") {
+		t.Fatalf("expected huge slow down to be refused")
+	}
+	if !c.slowDown(job, "1") {
+		t.Fatalf("expected slow down within the limit")
+	}
+}
+
+func TestThrottle_OtherHostsNotBlocked(t *testing.T) {
+	c := newTestCrawler(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.ctx = ctx
+
+	// slowed down host waits without holding the lock
+	c.lastReq["slow.org"] = time.Now().Add(time.Hour)
+	waited := make(chan error, 1)
+	go func() { waited <- c.throttle(Job{host: "slow.org"}) }()
+
+	done := make(chan error, 1)
+	go func() { done <- c.throttle(Job{host: "fast.org"}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("throttle: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("other host blocked by slowed down one")
+	}
+
+	cancel()
+	if err := <-waited; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+}
+
+func readTestMeta(t *testing.T, c *Crawler, rawURL string) pageMeta {
+	t.Helper()
+	u, _, _ := c.normalizeURL(rawURL)
+	host, id := pageID(u)
+	mb, err := os.ReadFile(c.metaPath(host, id))
+	if err != nil {
+		t.Fatalf("meta missing: %v", err)
+	}
+	var m pageMeta
+	if err := json.Unmarshal(mb, &m); err != nil {
+		t.Fatalf("meta json: %v", err)
+	}
+	return m
+}
+
+func TestDoRequest_Redirects(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCrawler(t, dir)
+	c.client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/old":     "31 /dir/new\r\n",
+		"gemini://example.org:1965/dir/new": "20 text/gemini\r\n=> sibling\n",
+		"gemini://example.org:1965/away":    "30 gemini://other.org/landing\r\n",
+		"gemini://example.org:1965/loop":    "30 /loop\r\n",
+		"gemini://example.org:1965/web":     "30 https://example.org/\r\n",
+	})
+
+	u, canon, _ := c.normalizeURL("gemini://example.org/old")
+	host, id := pageID(u)
+	if err, status, _ := c.doRequest(Job{link: u, canonical: canon, host: host, id: id}); err != nil {
+		t.Fatalf("doRequest: %v (%s)", err, status)
+	}
+	m := readTestMeta(t, c, "gemini://example.org/old")
+	if m.Status != "success" || len(m.Redirects) != 1 || m.Redirects[0] != "gemini://example.org/dir/new" {
+		t.Fatalf("same host redirect not recorded: %+v", m)
+	}
+	// links resolved against the final page
+	if link := <-c.jobsCandidates; link != "gemini://example.org/dir/sibling" {
+		t.Fatalf("unexpected discovered link: %s", link)
+	}
+
+	u, canon, _ = c.normalizeURL("gemini://example.org/away")
+	host, id = pageID(u)
+	if err, status, _ := c.doRequest(Job{link: u, canonical: canon, host: host, id: id}); err != nil {
+		t.Fatalf("doRequest: %v (%s)", err, status)
+	}
+	m = readTestMeta(t, c, "gemini://example.org/away")
+	if m.Status != "status-30" || len(m.Redirects) != 1 || m.Redirects[0] != "gemini://other.org/landing" {
+		t.Fatalf("cross host redirect not recorded: %+v", m)
+	}
+	if link := <-c.jobsCandidates; link != "gemini://other.org/landing" {
+		t.Fatalf("redirect target not queued: %s", link)
+	}
+
+	// loops and refused redirects are not retried
+	for _, path := range []string{"/loop", "/web"} {
+		u, canon, _ = c.normalizeURL("gemini://example.org" + path)
+		host, id = pageID(u)
+		if err, status, _ := c.doRequest(Job{link: u, canonical: canon, host: host, id: id}); err == nil || status != "redirect-error" {
+			t.Fatalf("%s: expected redirect-error, got %v (%s)", path, err, status)
+		}
+	}
+}
+
+func TestDoRequest_DecodesCharset(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCrawler(t, dir)
+	c.client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/cyr.gmi": "20 text/gemini; charset=koi8-r; lang=ru\r\n# \xf0\xd2\xc9\xd7\xc5\xd4\n",
+	})
+
+	u, canon, _ := c.normalizeURL("gemini://example.org/cyr.gmi")
+	host, id := pageID(u)
+	if err, status, _ := c.doRequest(Job{link: u, canonical: canon, host: host, id: id}); err != nil {
+		t.Fatalf("doRequest: %v (%s)", err, status)
+	}
+
+	m := readTestMeta(t, c, "gemini://example.org/cyr.gmi")
+	if m.MIME != "text/gemini; charset=utf-8; lang=ru" || m.Lang != "ru" {
+		t.Fatalf("unexpected meta: %+v", m)
+	}
+	contentPath, _ := c.contentPath(host, id, m.MIME)
+	b, err := os.ReadFile(contentPath)
+	if err != nil {
+		t.Fatalf("content missing: %v", err)
+	}
+	if string(b) != "# Привет\n" {
+		t.Fatalf("content not decoded: %q", b)
+	}
+}
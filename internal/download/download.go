@@ -0,0 +1,155 @@
+// Package download saves response bodies to disk and hands them off to external programs per media type
+package download
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+// DefaultDir returns Downloads dir in user home, or working dir if there is none
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		dir := filepath.Join(home, "Downloads")
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return "."
+}
+
+// extensions are preferred over mime package ones, which depend on system tables
+var extensions = map[string]string{
+	gemini.GeminiMediaType: ".gmi",
+	"text/plain":           ".txt",
+	"text/markdown":        ".md",
+	"text/html":            ".html",
+	"image/jpeg":           ".jpg",
+	"image/png":            ".png",
+	"image/gif":            ".gif",
+	"image/webp":           ".webp",
+	"audio/mpeg":           ".mp3",
+	"audio/ogg":            ".ogg",
+	"application/pdf":      ".pdf",
+	"application/zip":      ".zip",
+	"application/gzip":     ".gz",
+}
+
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// FileName derives a file name from the last path segment of link, "index" for the root,
+// an extension matching media type is added if the name has none
+func FileName(link *url.URL, mediaType gemini.MediaType) string {
+	name := path.Base(link.Path)
+	if name == "/" || name == "." {
+		name = "index"
+	}
+	name = strings.Trim(unsafeName.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		name = "download"
+	}
+
+	if path.Ext(name) == "" {
+		ext := extensions[mediaType.Type]
+		if ext == "" {
+			if exts, err := mime.ExtensionsByType(mediaType.Type); err == nil && len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+		name += ext
+	}
+	return name
+}
+
+// Save writes body to name in dir. Existing files are kept, "-1", "-2" and so on is added to the name instead.
+// Partially written file is removed on error
+func Save(dir, name string, body io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		target := filepath.Join(dir, name)
+		if i > 0 {
+			target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+		}
+
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(file, body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(target)
+			return "", err
+		}
+		return target, nil
+	}
+}
+
+// Handlers map media types to commands opening files of that type. A key is either
+// a full type like "image/png" or a major type with wildcard like "image/*"
+type Handlers map[string]string
+
+// Set parses "type=command" as flag value, so handlers can be given as repeated flags
+func (h Handlers) Set(value string) error {
+	mediaType, command, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(mediaType) == "" || strings.TrimSpace(command) == "" {
+		return fmt.Errorf("handler should be TYPE=COMMAND, got %q", value)
+	}
+	h[strings.ToLower(strings.TrimSpace(mediaType))] = strings.TrimSpace(command)
+	return nil
+}
+
+func (h Handlers) String() string {
+	var pairs []string
+	for mediaType, command := range h {
+		pairs = append(pairs, mediaType+"="+command)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Lookup returns command for media type, exact type wins over wildcard
+func (h Handlers) Lookup(mediaType string) (string, bool) {
+	if command, ok := h[mediaType]; ok {
+		return command, true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	command, ok := h[major+"/*"]
+	return command, ok
+}
+
+// Open runs command with file as the last argument and waits for it, so terminal programs can use the terminal
+func Open(command, file string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty handler command")
+	}
+
+	cmd := exec.Command(fields[0], append(fields[1:], file)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", fields[0], err)
+	}
+	return nil
+}
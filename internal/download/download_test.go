@@ -0,0 +1,80 @@
+package download
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		link string
+		meta string
+		want string
+	}{
+		{"gemini://example.org/images/cat.png", "image/png", "cat.png"},
+		{"gemini://example.org/images/cat", "image/jpeg", "cat.jpg"},
+		{"gemini://example.org/", "text/gemini", "index.gmi"},
+		{"gemini://example.org/docs/", "application/pdf", "docs.pdf"},
+		{"gemini://example.org/a%20b%3F.txt", "text/plain", "a-b-.txt"},
+		{"gemini://example.org/..", "application/x-unknown-type", "download"},
+	}
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		mediaType, _ := gemini.ParseMediaType(tt.meta)
+		if got := FileName(link, mediaType); got != tt.want {
+			t.Errorf("FileName(%s, %s) = %q, want %q", tt.link, tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "downloads")
+
+	first, err := Save(dir, "cat.png", strings.NewReader("one"))
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	second, err := Save(dir, "cat.png", strings.NewReader("two"))
+	if err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	if filepath.Base(first) != "cat.png" || filepath.Base(second) != "cat-1.png" {
+		t.Fatalf("unexpected paths: %s %s", first, second)
+	}
+	if body, _ := os.ReadFile(first); string(body) != "one" {
+		t.Errorf("existing file should be kept, got %q", body)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	handlers := Handlers{}
+	for _, value := range []string{"image/*=feh -F", "image/gif = mpv", "application/pdf=zathura"} {
+		if err := handlers.Set(value); err != nil {
+			t.Fatalf("set %q: %v", value, err)
+		}
+	}
+	if err := handlers.Set("image/png"); err == nil {
+		t.Errorf("expected error for handler without command")
+	}
+
+	tests := []struct {
+		mediaType string
+		want      string
+	}{
+		{"image/png", "feh -F"},
+		{"image/gif", "mpv"},
+		{"application/pdf", "zathura"},
+		{"audio/ogg", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := handlers.Lookup(tt.mediaType); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.mediaType, got, tt.want)
+		}
+	}
+}
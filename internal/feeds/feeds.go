@@ -0,0 +1,163 @@
+// Package feeds parses gemlogs in Gemini subscription format and Atom feeds,
+// keeps subscriptions and collects posts not seen yet
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+// ErrNotFeed is returned for pages without dated links which are not Atom feeds either
+var ErrNotFeed = errors.New("no dated links or Atom entries found")
+
+// Feed is a parsed gemlog
+type Feed struct {
+	URL   string
+	Title string
+	// Entries keep order of the page
+	Entries []Entry
+}
+
+// Entry is a single post of a feed
+type Entry struct {
+	URL   string
+	Date  time.Time
+	Title string
+	// Feed is title of the feed the post comes from
+	Feed string
+}
+
+// datedLabel matches link labels of Gemini subscription format: "YYYY-MM-DD title"
+var datedLabel = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\b[\s\-–—:]*(.*)$`)
+
+// Parse reads feed at link from a successful response body, Atom is detected by media type or XML content
+func Parse(link *url.URL, mediaType gemini.MediaType, body []byte) (Feed, error) {
+	var feed Feed
+	var err error
+	if isAtom(mediaType, body) {
+		feed, err = parseAtom(link, body)
+	} else if mediaType.IsGemtext() {
+		feed, err = parseGemtext(link, gemtext.ParseString(string(body)))
+	} else {
+		return Feed{}, fmt.Errorf("unsupported feed type: %s", mediaType.Type)
+	}
+	if err != nil {
+		return Feed{}, err
+	}
+
+	if len(feed.Entries) == 0 {
+		return Feed{}, ErrNotFeed
+	}
+	feed.URL = link.String()
+	if feed.Title == "" {
+		feed.Title = link.Host
+	}
+	for i := range feed.Entries {
+		feed.Entries[i].Feed = feed.Title
+	}
+	return feed, nil
+}
+
+func isAtom(mediaType gemini.MediaType, body []byte) bool {
+	switch mediaType.Type {
+	case "application/atom+xml", "application/xml", "text/xml":
+		return true
+	}
+	trimmed := bytes.TrimSpace(body)
+	return bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<feed"))
+}
+
+// parseGemtext collects links labeled with a date, feed title is the first top level heading
+func parseGemtext(base *url.URL, doc gemtext.Document) (Feed, error) {
+	feed := Feed{Title: doc.Title()}
+	for _, link := range doc.Links() {
+		match := datedLabel.FindStringSubmatch(link.Label)
+		if match == nil {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, match[1])
+		if err != nil {
+			continue
+		}
+		resolved, err := link.Resolve(base)
+		if err != nil {
+			continue
+		}
+
+		title := match[2]
+		if title == "" {
+			title = resolved.String()
+		}
+		feed.Entries = append(feed.Entries, Entry{URL: resolved.String(), Date: date, Title: title})
+	}
+	return feed, nil
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+func parseAtom(base *url.URL, body []byte) (Feed, error) {
+	var atom atomFeed
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		return Feed{}, fmt.Errorf("error parsing Atom feed: %w", err)
+	}
+
+	feed := Feed{Title: strings.TrimSpace(atom.Title)}
+	for _, entry := range atom.Entries {
+		href := ""
+		for _, link := range entry.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				href = link.Href
+				break
+			}
+		}
+		if href == "" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			continue
+		}
+
+		// published is when the post appeared, updated is required by Atom and used as fallback
+		stamp := entry.Published
+		if stamp == "" {
+			stamp = entry.Updated
+		}
+		date, err := time.Parse(time.RFC3339, strings.TrimSpace(stamp))
+		if err != nil {
+			continue
+		}
+
+		title := strings.Join(strings.Fields(entry.Title), " ")
+		resolved := base.ResolveReference(ref)
+		if title == "" {
+			title = resolved.String()
+		}
+		feed.Entries = append(feed.Entries, Entry{URL: resolved.String(), Date: date, Title: title})
+	}
+	return feed, nil
+}
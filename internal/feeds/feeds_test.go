@@ -0,0 +1,91 @@
+package feeds
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestParseGemtext(t *testing.T) {
+	link, _ := url.Parse("gemini://example.org/gemlog/")
+	body := "# My gemlog\n" +
+		"=> /about About\n" +
+		"=> 2024-05-02-post.gmi 2024-05-02 - Second post\n" +
+		"=> gemini://example.org/first.gmi 2024-05-01 First post\n" +
+		"=> untitled.gmi 2024-04-30\n" +
+		"=> bad.gmi 2024-13-01 Bad date\n"
+	mediaType, _ := gemini.ParseMediaType("text/gemini")
+
+	feed, err := Parse(link, mediaType, []byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if feed.Title != "My gemlog" || feed.URL != link.String() {
+		t.Errorf("unexpected feed: %q %q", feed.Title, feed.URL)
+	}
+
+	want := []Entry{
+		{"gemini://example.org/gemlog/2024-05-02-post.gmi", date("2024-05-02"), "Second post", "My gemlog"},
+		{"gemini://example.org/first.gmi", date("2024-05-01"), "First post", "My gemlog"},
+		{"gemini://example.org/gemlog/untitled.gmi", date("2024-04-30"), "gemini://example.org/gemlog/untitled.gmi", "My gemlog"},
+	}
+	if len(feed.Entries) != len(want) {
+		t.Fatalf("unexpected entries: %+v", feed.Entries)
+	}
+	for i := range want {
+		if feed.Entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, feed.Entries[i], want[i])
+		}
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	link, _ := url.Parse("gemini://example.org/atom.xml")
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom gemlog</title>
+  <entry>
+    <title>Post
+      title</title>
+    <link rel="alternate" href="/posts/1.gmi"/>
+    <updated>2024-05-03T10:00:00Z</updated>
+    <published>2024-05-01T10:00:00Z</published>
+  </entry>
+  <entry>
+    <title>No link</title>
+    <updated>2024-05-03T10:00:00Z</updated>
+  </entry>
+</feed>`
+	// feeds served as text/plain are still detected by content
+	mediaType, _ := gemini.ParseMediaType("text/plain")
+
+	feed, err := Parse(link, mediaType, []byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := Entry{"gemini://example.org/posts/1.gmi", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), "Post title", "Atom gemlog"}
+	if len(feed.Entries) != 1 || feed.Entries[0] != want {
+		t.Fatalf("unexpected entries: %+v", feed.Entries)
+	}
+}
+
+func TestParseNotFeed(t *testing.T) {
+	link, _ := url.Parse("gemini://example.org/")
+	mediaType, _ := gemini.ParseMediaType("text/gemini")
+	if _, err := Parse(link, mediaType, []byte("# Home\n=> /about About\n")); !errors.Is(err, ErrNotFeed) {
+		t.Errorf("expected ErrNotFeed, got %v", err)
+	}
+
+	mediaType, _ = gemini.ParseMediaType("image/png")
+	if _, err := Parse(link, mediaType, []byte("png")); err == nil {
+		t.Errorf("expected error for unsupported type")
+	}
+}
@@ -0,0 +1,269 @@
+package feeds
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+// Subscription is a followed feed
+type Subscription struct {
+	URL   string
+	Title string
+}
+
+// Subscriptions keeps followed feeds and URLs of posts already seen in a directory:
+// "subscriptions" has one "url\ttitle" line per feed, "seen" one post URL per line
+type Subscriptions struct {
+	dir           string
+	subscriptions []Subscription
+	seen          map[string]bool
+	mu            sync.Mutex
+}
+
+// Fetch requests a feed, e.g. gemini.Client.DoRequest
+type Fetch func(link *url.URL) (*gemini.Response, error)
+
+// DefaultDir returns subscriptions location in user config dir
+func DefaultDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "feeds"), nil
+}
+
+// NewSubscriptions loads subscriptions from dir, missing dir means no subscriptions yet
+func NewSubscriptions(dir string) (*Subscriptions, error) {
+	s := &Subscriptions{dir: dir, seen: make(map[string]bool)}
+
+	err := readLines(filepath.Join(dir, "subscriptions"), func(line string) error {
+		link, title, _ := strings.Cut(line, "\t")
+		s.subscriptions = append(s.subscriptions, Subscription{URL: link, Title: title})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readLines(filepath.Join(dir, "seen"), func(line string) error {
+		s.seen[line] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func readLines(path string, handle func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scan %s: %w", path, err)
+	}
+	return nil
+}
+
+// List returns subscriptions in order they were added
+func (s *Subscriptions) List() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.subscriptions)
+}
+
+// Subscribe follows feed, its current entries are marked as seen so only later posts are new
+func (s *Subscriptions) Subscribe(feed Feed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.subscriptions, func(sub Subscription) bool { return sub.URL == feed.URL }) {
+		return fmt.Errorf("already subscribed to %s", feed.URL)
+	}
+
+	subscriptions := append(slices.Clone(s.subscriptions), Subscription{URL: feed.URL, Title: strings.Join(strings.Fields(feed.Title), " ")})
+	if err := s.saveSubscriptions(subscriptions); err != nil {
+		return err
+	}
+	return s.markSeen(feed.Entries)
+}
+
+// Unsubscribe stops following feed with number, starting from 1, as listed by List
+func (s *Subscriptions) Unsubscribe(number int) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if number < 1 || number > len(s.subscriptions) {
+		return Subscription{}, fmt.Errorf("no subscription with number %d", number)
+	}
+	removed := s.subscriptions[number-1]
+	return removed, s.saveSubscriptions(slices.Delete(slices.Clone(s.subscriptions), number-1, number))
+}
+
+// FetchFeed requests link and parses the response as a feed
+func FetchFeed(fetch Fetch, link *url.URL) (Feed, error) {
+	resp, err := fetch(link)
+	if err != nil {
+		return Feed{}, err
+	}
+	if resp.Status != gemini.StatusSuccess {
+		if err := resp.Err(); err != nil {
+			return Feed{}, err
+		}
+		return Feed{}, fmt.Errorf("unexpected response: %d %s", resp.Code, resp.Meta)
+	}
+	if resp.URL != nil {
+		// relative links refer to the page we landed on
+		link = resp.URL
+	}
+
+	mediaType, err := resp.MediaType()
+	if err != nil {
+		return Feed{}, err
+	}
+	mediaType, body, err := gemini.DecodeText(mediaType, resp.Body)
+	if err != nil {
+		return Feed{}, err
+	}
+	return Parse(link, mediaType, body)
+}
+
+// Refresh fetches all subscriptions and returns entries not seen yet, newest first.
+// Feeds failing to load are reported in errs and skipped
+func (s *Subscriptions) Refresh(fetch Fetch) (unseen []Entry, errs []error) {
+	for _, sub := range s.List() {
+		link, err := gemini.GetFullGeminiLink(sub.URL)
+		if err == nil {
+			var feed Feed
+			if feed, err = FetchFeed(fetch, link); err == nil {
+				unseen = append(unseen, s.unseen(feed.Entries)...)
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", sub.URL, err))
+	}
+
+	sort.SliceStable(unseen, func(i, j int) bool { return unseen[i].Date.After(unseen[j].Date) })
+	return unseen, errs
+}
+
+func (s *Subscriptions) unseen(entries []Entry) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unseen []Entry
+	for _, entry := range entries {
+		if !s.seen[entry.URL] {
+			unseen = append(unseen, entry)
+		}
+	}
+	return unseen
+}
+
+// MarkSeen remembers entries, so they are not returned by Refresh again
+func (s *Subscriptions) MarkSeen(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.markSeen(entries)
+}
+
+func (s *Subscriptions) markSeen(entries []Entry) error {
+	var b strings.Builder
+	for _, entry := range entries {
+		if !s.seen[entry.URL] {
+			b.WriteString(entry.URL + "\n")
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, "seen"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(b.String()); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		s.seen[entry.URL] = true
+	}
+	return nil
+}
+
+// saveSubscriptions writes subscriptions to a temporary file and renames it, so a failed write keeps the old file
+func (s *Subscriptions) saveSubscriptions(subscriptions []Subscription) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, sub := range subscriptions {
+		fmt.Fprintf(&b, "%s\t%s\n", sub.URL, sub.Title)
+	}
+
+	path := filepath.Join(s.dir, "subscriptions")
+	if err := os.WriteFile(path+".tmp", []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	s.subscriptions = subscriptions
+	return nil
+}
+
+// Page renders entries as a gemtext page in Gemini subscription format, grouped by day
+func Page(entries []Entry) gemtext.Document {
+	doc := gemtext.Document{gemtext.Heading{Level: 1, Text: "New posts"}}
+	if len(entries) == 0 {
+		return append(doc, gemtext.Text("Nothing new"))
+	}
+
+	day := ""
+	for _, entry := range entries {
+		if date := entry.Date.Format(time.DateOnly); date != day {
+			day = date
+			doc = append(doc, gemtext.Text(""), gemtext.Heading{Level: 2, Text: day})
+		}
+		doc = append(doc, gemtext.Link{
+			URL:   entry.URL,
+			Label: fmt.Sprintf("%s %s - %s", entry.Date.Format(time.DateOnly), entry.Feed, entry.Title),
+		})
+	}
+	return doc
+}
@@ -0,0 +1,103 @@
+package feeds
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+// fakeFetch serves gemtext pages by URL, missing ones fail
+type fakeFetch map[string]string
+
+func (f fakeFetch) fetch(link *url.URL) (*gemini.Response, error) {
+	body, ok := f[link.String()]
+	if !ok {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return &gemini.Response{Status: gemini.StatusSuccess, Code: gemini.CodeSuccess, Meta: "text/gemini", Body: []byte(body)}, nil
+}
+
+func TestSubscriptions(t *testing.T) {
+	dir := t.TempDir()
+	pages := fakeFetch{
+		"gemini://a.org:1965/": "# A\n=> /1 2024-05-01 A first\n",
+		"gemini://b.org:1965/": "# B\n=> /1 2024-05-02 B first\n",
+	}
+
+	subs, err := NewSubscriptions(dir)
+	if err != nil {
+		t.Fatalf("new subscriptions: %v", err)
+	}
+	for _, raw := range []string{"gemini://a.org:1965/", "gemini://b.org:1965/"} {
+		link, _ := url.Parse(raw)
+		feed, err := FetchFeed(pages.fetch, link)
+		if err != nil {
+			t.Fatalf("fetch feed: %v", err)
+		}
+		if err := subs.Subscribe(feed); err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+	}
+	if err := subs.Subscribe(Feed{URL: "gemini://a.org:1965/"}); err == nil {
+		t.Errorf("expected error subscribing twice")
+	}
+
+	// posts present when subscribing are not new
+	if unseen, errs := subs.Refresh(pages.fetch); len(unseen) != 0 || len(errs) != 0 {
+		t.Fatalf("unexpected refresh: %+v %v", unseen, errs)
+	}
+
+	pages["gemini://a.org:1965/"] += "=> /2 2024-05-03 A second\n"
+	pages["gemini://b.org:1965/"] += "=> /2 2024-05-04 B second\n"
+
+	reloaded, err := NewSubscriptions(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := reloaded.List(); len(list) != 2 || list[0].Title != "A" {
+		t.Fatalf("unexpected subscriptions: %+v", list)
+	}
+
+	unseen, errs := reloaded.Refresh(pages.fetch)
+	if len(errs) != 0 || len(unseen) != 2 || unseen[0].Title != "B second" || unseen[1].Title != "A second" {
+		t.Fatalf("unexpected new posts: %+v %v", unseen, errs)
+	}
+	if err := reloaded.MarkSeen(unseen); err != nil {
+		t.Fatalf("mark seen: %v", err)
+	}
+
+	delete(pages, "gemini://b.org:1965/")
+	unseen, errs = reloaded.Refresh(pages.fetch)
+	if len(unseen) != 0 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "b.org") {
+		t.Fatalf("failed feed should be reported: %+v %v", unseen, errs)
+	}
+
+	if removed, err := reloaded.Unsubscribe(2); err != nil || removed.URL != "gemini://b.org:1965/" {
+		t.Fatalf("unsubscribe: %+v %v", removed, err)
+	}
+	if _, err := reloaded.Unsubscribe(2); err == nil {
+		t.Errorf("expected error for missing subscription")
+	}
+}
+
+func TestPage(t *testing.T) {
+	entries := []Entry{
+		{"gemini://b.org/2", date("2024-05-04"), "B second", "B"},
+		{"gemini://a.org/3", date("2024-05-04"), "A third", "A"},
+		{"gemini://a.org/2", date("2024-05-03"), "A second", "A"},
+	}
+	want := "# New posts\n\n## 2024-05-04\n" +
+		"=> gemini://b.org/2 2024-05-04 B - B second\n" +
+		"=> gemini://a.org/3 2024-05-04 A - A third\n" +
+		"\n## 2024-05-03\n" +
+		"=> gemini://a.org/2 2024-05-03 A - A second\n"
+	if got := Page(entries).String(); got != want {
+		t.Errorf("unexpected page:\n%s", got)
+	}
+	if got := Page(nil).String(); got != "# New posts\nNothing new\n" {
+		t.Errorf("unexpected empty page: %q", got)
+	}
+}
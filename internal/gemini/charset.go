@@ -0,0 +1,118 @@
+package gemini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// UnsupportedCharsetError is returned for charsets which cannot be decoded
+type UnsupportedCharsetError struct {
+	Charset string
+}
+
+func (e *UnsupportedCharsetError) Error() string {
+	return fmt.Sprintf("unsupported charset: %s", e.Charset)
+}
+
+// charsetTables maps normalized charset names to their upper halves, nil means latin-1
+var charsetTables = map[string]*[128]rune{
+	"iso-8859-1":   nil,
+	"iso8859-1":    nil,
+	"iso_8859-1":   nil,
+	"latin1":       nil,
+	"latin-1":      nil,
+	"l1":           nil,
+	"windows-1252": &windows1252,
+	"cp1252":       &windows1252,
+	"windows-1251": &windows1251,
+	"cp1251":       &windows1251,
+	"koi8-r":       &koi8r,
+	"koi8r":        &koi8r,
+	"koi8-u":       &koi8u,
+	"koi8u":        &koi8u,
+	"iso-8859-5":   &iso88595,
+	"iso8859-5":    &iso88595,
+	"iso-8859-15":  &iso885915,
+	"iso8859-15":   &iso885915,
+	"latin-9":      &iso885915,
+	"latin9":       &iso885915,
+	"ibm866":       &ibm866,
+	"cp866":        &ibm866,
+}
+
+// IsUTF8Charset reports whether charset needs no decoding, empty charset is UTF-8 by spec
+func IsUTF8Charset(charset string) bool {
+	switch normalizeCharset(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return true
+	}
+	return false
+}
+
+func normalizeCharset(charset string) string {
+	return strings.ToLower(strings.TrimSpace(charset))
+}
+
+// DecodeCharset converts body in the given charset to UTF-8
+func DecodeCharset(body []byte, charset string) ([]byte, error) {
+	reader, err := NewCharsetReader(strings.NewReader(string(body)), charset)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// NewCharsetReader returns a reader converting r from the given charset to UTF-8
+func NewCharsetReader(r io.Reader, charset string) (io.Reader, error) {
+	if IsUTF8Charset(charset) {
+		return r, nil
+	}
+
+	table, ok := charsetTables[normalizeCharset(charset)]
+	if !ok {
+		return nil, &UnsupportedCharsetError{Charset: charset}
+	}
+	return &charsetReader{reader: bufio.NewReader(r), table: table}, nil
+}
+
+// charsetReader decodes single-byte charsets, where every byte is a rune
+type charsetReader struct {
+	reader  *bufio.Reader
+	table   *[128]rune
+	pending []byte
+}
+
+func (c *charsetReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(c.pending) > 0 {
+			copied := copy(p[n:], c.pending)
+			c.pending = c.pending[copied:]
+			n += copied
+			continue
+		}
+
+		// do not block for more input once something can be returned
+		if n > 0 && c.reader.Buffered() == 0 {
+			break
+		}
+
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+
+		r := rune(b)
+		if b >= 0x80 && c.table != nil {
+			r = c.table[b-0x80]
+		}
+		c.pending = utf8.AppendRune(c.pending[:0], r)
+	}
+	return n, nil
+}
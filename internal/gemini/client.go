@@ -0,0 +1,312 @@
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Client performs Gemini requests over a configurable transport.
+// Zero value is usable, but has no timeouts - prefer NewClient
+type Client struct {
+	// Dialer opens TCP connections, nil means a net.Dialer with Timeouts.Dial
+	Dialer *net.Dialer
+	// DialTLSContext replaces both dialing and TLS handshake when set,
+	// e.g. to serve requests from memory in tests
+	DialTLSContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	// TLSConfig is cloned for every connection, nil accepts any server certificate
+	TLSConfig *tls.Config
+	// KnownHosts pins server certificates on first use, nil disables the check
+	KnownHosts KnownHosts
+	// Identities provides client certificates by URL prefix, nil presents none
+	Identities *IdentityStore
+
+	Timeouts Timeouts
+	// MaxRedirects limits redirects followed, zero means package MaxRedirects
+	// and negative value returns redirect responses as is
+	MaxRedirects int
+	// CheckRedirect is called before following a redirect, returned error stops the request.
+	// ErrUseLastResponse returns the redirect response instead, nil follows any gemini redirect
+	CheckRedirect func(redirect Redirect) error
+	// MaxBodySize limits response body in bytes, zero means unlimited
+	MaxBodySize int64
+
+	// OnRequest is called before every request, redirects included
+	OnRequest func(link *url.URL)
+	// OnResponse is called after every response is read, redirects included
+	OnResponse func(link *url.URL, resp *Response)
+}
+
+// DefaultClient is used by package level request functions
+var DefaultClient = NewClient()
+
+func NewClient() *Client {
+	return &Client{Timeouts: DefaultTimeouts}
+}
+
+// DoRequest performs a Gemini request with redirect handling
+func (c *Client) DoRequest(link *url.URL) (*Response, error) {
+	return c.DoRequestContext(context.Background(), link)
+}
+
+// DoRequestContext performs a Gemini request with redirect handling,
+// cancelling in-flight network operations once ctx is done
+func (c *Client) DoRequestContext(ctx context.Context, link *url.URL) (*Response, error) {
+	return c.request(ctx, link, false)
+}
+
+// Stream performs a Gemini request like DoRequestContext, but leaves body of a successful
+// response unread in Response.BodyReader. Caller must close it, ctx has to stay alive
+// until then. MaxBodySize and body timeout are enforced while reading
+func (c *Client) Stream(ctx context.Context, link *url.URL) (*Response, error) {
+	return c.request(ctx, link, true)
+}
+
+func (c *Client) request(ctx context.Context, link *url.URL, stream bool) (*Response, error) {
+	redirectsLeft := c.MaxRedirects
+	if redirectsLeft == 0 {
+		redirectsLeft = MaxRedirects
+	}
+
+	var via []*url.URL
+	for {
+		if c.OnRequest != nil {
+			c.OnRequest(link)
+		}
+
+		resp, err := c.do(ctx, link, stream)
+		resp.URL = link
+		resp.Redirects = via
+		if err != nil {
+			return resp, err
+		}
+
+		if c.OnResponse != nil {
+			c.OnResponse(link, resp)
+		}
+
+		if resp.Status != StatusRedirect || redirectsLeft < 0 {
+			return resp, nil
+		}
+
+		if redirectsLeft == 0 {
+			return resp, fmt.Errorf("%w: too many redirects, last url: %s", ErrRedirectRefused, resp.Meta)
+		}
+
+		next, err := resolveRedirect(link, resp.Meta)
+		if err != nil {
+			return resp, err
+		}
+
+		via = append(via, link)
+		if err := c.checkRedirect(Redirect{
+			From:      link,
+			To:        next,
+			Permanent: resp.Code == CodeRedirectPermanent,
+			Via:       via,
+		}); err != nil {
+			if errors.Is(err, ErrUseLastResponse) {
+				return resp, nil
+			}
+			return resp, err
+		}
+
+		link = next
+		redirectsLeft -= 1
+	}
+}
+
+func (c *Client) checkRedirect(redirect Redirect) error {
+	if c.CheckRedirect != nil {
+		if err := c.CheckRedirect(redirect); err != nil {
+			if errors.Is(err, ErrUseLastResponse) {
+				return err
+			}
+			return fmt.Errorf("%w: %w", ErrRedirectRefused, err)
+		}
+	}
+
+	if redirect.To.Scheme != "gemini" {
+		return fmt.Errorf("%w: cannot follow redirect to %s", ErrRedirectRefused, redirect.To)
+	}
+	for _, visited := range redirect.Via {
+		if visited.String() == redirect.To.String() {
+			return fmt.Errorf("%w: %s", ErrRedirectLoop, redirect.To)
+		}
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, link *url.URL, stream bool) (*Response, error) {
+	var cert *tls.Certificate
+	if c.Identities != nil {
+		if identity := c.Identities.ForURL(link); identity != nil {
+			cert = &identity.Certificate
+		}
+	}
+
+	started := time.Now()
+	conn, trust, err := c.dial(ctx, link.Host, cert)
+	if err != nil {
+		return NewResponseEmpty(), fmt.Errorf("connection failed: %w", err)
+	}
+	info := newConnInfo(conn, trust)
+	info.Connect = time.Since(started)
+
+	// unblock any pending read or write as soon as ctx is done
+	deadline := &deadlines{conn: conn}
+	stop := context.AfterFunc(ctx, deadline.cancel)
+	body := &bodyReader{ctx: ctx, conn: conn, stop: stop, info: info, started: started}
+
+	deadline.set(c.Timeouts.Header)
+	_, err = conn.Write([]byte(link.String() + "\r\n"))
+	if err != nil {
+		_ = body.Close()
+		return NewResponseEmpty(), contextErr(ctx, fmt.Errorf("sending request url failed: %w", err))
+	}
+
+	reader := bufio.NewReader(conn)
+	code, meta, err := readHeader(reader)
+	info.Header = time.Since(started)
+	resp := NewResponseCode(code, meta, nil)
+	resp.Conn = info
+	if err != nil || resp.Status != StatusSuccess {
+		_ = body.Close()
+		return resp, contextErr(ctx, err)
+	}
+
+	deadline.set(c.Timeouts.Body)
+	body.reader = LimitReader(reader, c.MaxBodySize)
+	if stream {
+		resp.BodyReader = body
+		return resp, nil
+	}
+	defer body.Close()
+
+	resp.Body, err = io.ReadAll(body)
+	return resp, err
+}
+
+// bodyReader reads response body and releases the connection on Close,
+// body size and timing are recorded in info
+type bodyReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	conn    net.Conn
+	stop    func() bool
+	info    *ConnInfo
+	started time.Time
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.info.Size += int64(n)
+	if err == nil || err == io.EOF || errors.Is(err, ErrBodyTooLarge) {
+		return n, err
+	}
+	return n, contextErr(b.ctx, fmt.Errorf("response body reading failed: %w", err))
+}
+
+func (b *bodyReader) Close() error {
+	if b.info.Total == 0 {
+		b.info.Total = time.Since(b.started)
+	}
+	b.stop()
+	return b.conn.Close()
+}
+
+// dial opens a TLS connection to addr, applying dial and handshake timeouts separately,
+// cert is presented as client certificate if not nil
+func (c *Client) dial(ctx context.Context, addr string, cert *tls.Certificate) (net.Conn, Trust, error) {
+	conn, err := c.dialTLS(ctx, addr, cert)
+	if err != nil {
+		return nil, TrustUnchecked, err
+	}
+
+	trust, err := c.verifyKnownHost(conn, addr)
+	if err != nil {
+		_ = conn.Close()
+		return nil, TrustUnchecked, err
+	}
+	return conn, trust, nil
+}
+
+func (c *Client) dialTLS(ctx context.Context, addr string, cert *tls.Certificate) (net.Conn, error) {
+	if c.DialTLSContext != nil {
+		return c.DialTLSContext(ctx, "tcp", addr)
+	}
+
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: c.Timeouts.Dial}
+	}
+
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	handshakeCtx := ctx
+	if c.Timeouts.Handshake > 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, c.Timeouts.Handshake)
+		defer cancel()
+	}
+
+	conn := tls.Client(rawConn, c.tlsConfig(addr, cert))
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		_ = rawConn.Close()
+		return nil, fmt.Errorf("tls handshake failed: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (c *Client) verifyKnownHost(conn net.Conn, addr string) (Trust, error) {
+	if c.KnownHosts == nil {
+		return TrustUnchecked, nil
+	}
+
+	tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	if !ok {
+		// in-memory connections have nothing to pin
+		return TrustUnchecked, nil
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return TrustUnchecked, fmt.Errorf("no server certificate presented")
+	}
+	return verifyKnownHost(c.KnownHosts, addr, certs[0], time.Now())
+}
+
+func (c *Client) tlsConfig(addr string, cert *tls.Certificate) *tls.Config {
+	var config *tls.Config
+	if c.TLSConfig != nil {
+		config = c.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = hostnameOf(addr)
+	}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return config
+}
+
+func hostnameOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
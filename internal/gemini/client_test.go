@@ -0,0 +1,225 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
+)
+
+func TestClientDoRequestInMemory(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/": "20 text/gemini\r\n# Hello\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/")
+	resp, err := client.DoRequest(link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Status != StatusSuccess || resp.Meta != GeminiMediaType || string(resp.Body) != "# Hello\n" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestClientRedirectsAndHooks(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/old": "31 gemini://example.org/new\r\n",
+		"gemini://example.org:1965/new": "20 text/plain\r\nmoved",
+	})
+
+	var requested []string
+	responses := 0
+	client.OnRequest = func(link *url.URL) { requested = append(requested, link.String()) }
+	client.OnResponse = func(link *url.URL, resp *Response) { responses++ }
+
+	link, _ := GetFullGeminiLink("example.org/old")
+	resp, err := client.DoRequest(link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Body) != "moved" {
+		t.Fatalf("redirect not followed: %+v", resp)
+	}
+	if len(requested) != 2 || responses != 2 {
+		t.Fatalf("hooks not called per request: %v, %d responses", requested, responses)
+	}
+
+	// negative limit returns redirect as is
+	client.MaxRedirects = -1
+	resp, err = client.DoRequest(link)
+	if err != nil || resp.Status != StatusRedirect {
+		t.Fatalf("expected redirect response, got %+v, %v", resp, err)
+	}
+}
+
+func TestClientTooManyRedirects(t *testing.T) {
+	client := NewClient()
+	client.MaxRedirects = 1
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/a": "30 gemini://example.org/b\r\n",
+		"gemini://example.org:1965/b": "30 gemini://example.org/a\r\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/a")
+	_, err := client.DoRequest(link)
+	if err == nil || !strings.Contains(err.Error(), "too many redirects") {
+		t.Fatalf("expected too many redirects error, got %v", err)
+	}
+}
+
+func TestClientMaxBodySize(t *testing.T) {
+	client := NewClient()
+	client.MaxBodySize = 4
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/": "20 text/plain\r\n0123456789",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/")
+	resp, err := client.DoRequest(link)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected body too large error, got %v", err)
+	}
+	if len(resp.Body) != 4 {
+		t.Fatalf("expected truncated body, got %q", resp.Body)
+	}
+}
+
+func TestClientResponseCode(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/login": "11 Password\r\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/login")
+	resp, err := client.DoRequest(link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Status != StatusInput || resp.Code != CodeSensitiveInput || resp.Meta != "Password" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	client := NewClient()
+	client.MaxBodySize = 10
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/small": "20 text/plain\r\n0123456789",
+		"gemini://example.org:1965/large": "20 application/octet-stream\r\n0123456789abcdef",
+		"gemini://example.org:1965/gone":  "52 gone\r\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/small")
+	resp, err := client.Stream(context.Background(), link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Body != nil || resp.BodyReader == nil {
+		t.Fatalf("expected unread body: %+v", resp)
+	}
+	body, err := io.ReadAll(resp.BodyReader)
+	resp.BodyReader.Close()
+	if err != nil || string(body) != "0123456789" {
+		t.Fatalf("body exactly at the limit should be read: %q, %v", body, err)
+	}
+
+	link, _ = GetFullGeminiLink("example.org/large")
+	resp, err = client.Stream(context.Background(), link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err = io.ReadAll(resp.BodyReader)
+	resp.BodyReader.Close()
+	if !errors.Is(err, ErrBodyTooLarge) || string(body) != "0123456789" {
+		t.Fatalf("expected capped body with error: %q, %v", body, err)
+	}
+
+	link, _ = GetFullGeminiLink("example.org/gone")
+	resp, err = client.Stream(context.Background(), link)
+	if err != nil || resp.Code != CodeGone || resp.BodyReader != nil {
+		t.Fatalf("failure response should have no body: %+v, %v", resp, err)
+	}
+}
+
+func TestClientRelativeRedirectChain(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/dir/old":   "31 new\r\n",
+		"gemini://example.org:1965/dir/new":   "30 /final?x=1\r\n",
+		"gemini://example.org:1965/final?x=1": "20 text/plain\r\nlanded",
+	})
+
+	var permanent []bool
+	client.CheckRedirect = func(redirect Redirect) error {
+		permanent = append(permanent, redirect.Permanent)
+		return nil
+	}
+
+	link, _ := GetFullGeminiLink("example.org/dir/old")
+	resp, err := client.DoRequest(link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.URL.String() != "gemini://example.org:1965/final?x=1" {
+		t.Errorf("unexpected final URL: %s", resp.URL)
+	}
+	if len(resp.Redirects) != 2 ||
+		resp.Redirects[0].String() != "gemini://example.org:1965/dir/old" ||
+		resp.Redirects[1].String() != "gemini://example.org:1965/dir/new" {
+		t.Errorf("unexpected redirect chain: %v", resp.Redirects)
+	}
+	if len(permanent) != 2 || !permanent[0] || permanent[1] {
+		t.Errorf("permanent flags mismatch: %v", permanent)
+	}
+}
+
+func TestClientRedirectLoop(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/a": "30 /b\r\n",
+		"gemini://example.org:1965/b": "30 gemini://example.org:1965/a\r\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/a")
+	_, err := client.DoRequest(link)
+	if !errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expected redirect loop error, got %v", err)
+	}
+}
+
+func TestClientSameHostRedirects(t *testing.T) {
+	client := NewClient()
+	client.CheckRedirect = SameHostRedirects
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/moved": "31 gemini://other.org/moved\r\n",
+		"gemini://example.org:1965/web":   "30 https://example.org/\r\n",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/moved")
+	resp, err := client.DoRequest(link)
+	if err != nil || resp.Code != CodeRedirectPermanent || resp.Meta != "gemini://other.org/moved" {
+		t.Fatalf("cross-host redirect should be returned as is: %+v, %v", resp, err)
+	}
+
+	// default policy cannot follow other schemes
+	client.CheckRedirect = nil
+	link, _ = GetFullGeminiLink("example.org/web")
+	if _, err := client.DoRequest(link); !errors.Is(err, ErrRedirectRefused) || !strings.Contains(err.Error(), "cannot follow redirect") {
+		t.Fatalf("expected cross-scheme error, got %v", err)
+	}
+
+	// errors of custom policies are refusals too
+	policyErr := errors.New("no redirects")
+	client.CheckRedirect = func(Redirect) error { return policyErr }
+	link, _ = GetFullGeminiLink("example.org/moved")
+	if _, err := client.DoRequest(link); !errors.Is(err, ErrRedirectRefused) || !errors.Is(err, policyErr) {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package gemini
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ConnInfo describes connection a response was received over
+type ConnInfo struct {
+	// Addr is resolved remote address, IP and port for TCP connections
+	Addr string
+	// TLS is nil for connections without TLS state, e.g. in-memory ones
+	TLS   *tls.ConnectionState
+	Trust Trust
+
+	// Connect is time spent dialing and in TLS handshake, Header is time until response header was read,
+	// Total is time until body was read. All are counted from the start of the request
+	Connect time.Duration
+	Header  time.Duration
+	Total   time.Duration
+	// Size is number of body bytes read, streamed bodies update Size and Total while they are read
+	Size int64
+}
+
+func newConnInfo(conn net.Conn, trust Trust) *ConnInfo {
+	info := &ConnInfo{Trust: trust}
+	if addr := conn.RemoteAddr(); addr != nil {
+		info.Addr = addr.String()
+	}
+	if tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		state := tlsConn.ConnectionState()
+		info.TLS = &state
+	}
+	return info
+}
+
+// Describe lists status, connection and server certificate details of response as "name: value" lines
+func (r *Response) Describe() []string {
+	lines := []string{}
+	if r.URL != nil {
+		lines = append(lines, "url: "+r.URL.String())
+	}
+	for _, link := range r.Redirects {
+		lines = append(lines, "redirected from: "+link.String())
+	}
+	lines = append(lines,
+		fmt.Sprintf("status: %d %s", r.Code, StatusText(r.Code)),
+		"meta: "+r.Meta)
+
+	info := r.Conn
+	if info == nil {
+		return append(lines, "connection: none, response was not received over network")
+	}
+
+	lines = append(lines, "address: "+info.Addr)
+	if info.TLS != nil {
+		lines = append(lines, fmt.Sprintf("tls: %s, %s",
+			tls.VersionName(info.TLS.Version), tls.CipherSuiteName(info.TLS.CipherSuite)))
+
+		if certs := info.TLS.PeerCertificates; len(certs) > 0 {
+			cert := certs[0]
+			names := append([]string{}, cert.DNSNames...)
+			for _, ip := range cert.IPAddresses {
+				names = append(names, ip.String())
+			}
+
+			expiry := "expires " + cert.NotAfter.UTC().Format(time.DateOnly)
+			if time.Now().After(cert.NotAfter) {
+				expiry = "expired " + cert.NotAfter.UTC().Format(time.DateOnly)
+			}
+			lines = append(lines,
+				"certificate subject: "+cert.Subject.String(),
+				"certificate names: "+strings.Join(names, ", "),
+				fmt.Sprintf("certificate validity: from %s, %s", cert.NotBefore.UTC().Format(time.DateOnly), expiry),
+				"certificate fingerprint: sha256 "+Fingerprint(cert))
+		}
+	}
+	lines = append(lines, "tofu: "+info.Trust.String())
+
+	timing := fmt.Sprintf("timing: connect %s, header %s", info.Connect.Round(time.Millisecond), info.Header.Round(time.Millisecond))
+	if info.Total > 0 {
+		timing += fmt.Sprintf(", total %s", info.Total.Round(time.Millisecond))
+	}
+	return append(lines, fmt.Sprintf("size: %d bytes", info.Size), timing)
+}
@@ -0,0 +1,92 @@
+package gemini
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
+)
+
+func TestConnInfoTLS(t *testing.T) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{newTestCert(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 1024)
+			_, _ = conn.Read(buf)
+			_, _ = conn.Write([]byte("20 text/gemini\r\n# Hello\n"))
+			conn.Close()
+		}
+	}()
+
+	knownHosts, err := NewFileKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient()
+	client.KnownHosts = knownHosts
+	link, _ := url.Parse("gemini://" + listener.Addr().String() + "/")
+
+	resp, err := client.DoRequest(link)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	info := resp.Conn
+	if info == nil || info.TLS == nil || info.Addr != listener.Addr().String() {
+		t.Fatalf("unexpected connection info: %+v", info)
+	}
+	if info.Trust != TrustPinned || info.Size != 8 || info.Header < info.Connect || info.Total < info.Header {
+		t.Fatalf("unexpected trust, size or timing: %+v", info)
+	}
+
+	described := strings.Join(resp.Describe(), "\n")
+	for _, want := range []string{"status: 20 SUCCESS", "meta: text/gemini", "tls: TLS 1.3", "certificate fingerprint: sha256 ", "tofu: first use", "size: 8 bytes"} {
+		if !strings.Contains(described, want) {
+			t.Errorf("%q missing in:\n%s", want, described)
+		}
+	}
+
+	if resp, err = client.DoRequest(link); err != nil || resp.Conn.Trust != TrustKnown {
+		t.Fatalf("pinned certificate should be known: %+v, %v", resp.Conn, err)
+	}
+}
+
+func TestConnInfoStream(t *testing.T) {
+	client := NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/": "20 text/plain\r\n0123456789",
+	})
+
+	link, _ := GetFullGeminiLink("example.org/")
+	resp, err := client.Stream(context.Background(), link)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Conn == nil || resp.Conn.TLS != nil || resp.Conn.Size != 0 || resp.Conn.Total != 0 {
+		t.Fatalf("unexpected info before body is read: %+v", resp.Conn)
+	}
+
+	_, _ = io.ReadAll(resp.BodyReader)
+	resp.BodyReader.Close()
+	if resp.Conn.Size != 10 || resp.Conn.Total == 0 {
+		t.Fatalf("streamed body should update info: %+v", resp.Conn)
+	}
+
+	cached := NewResponseCode(CodeSuccess, "text/plain", nil)
+	if lines := cached.Describe(); !strings.Contains(lines[len(lines)-1], "not received over network") {
+		t.Errorf("unexpected description of cached response: %v", lines)
+	}
+}
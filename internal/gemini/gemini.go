@@ -2,16 +2,19 @@ package gemini
 
 import (
 	"bufio"
-	"crypto/tls"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+var ErrBodyTooLarge = errors.New("response body is too large")
+
 const (
 	Port            = "1965"
 	GeminiMediaType = "text/gemini"
@@ -28,17 +31,50 @@ const (
 
 	Protocol     = "gemini://"
 	MaxRedirects = 4
+	// MaxURLLength is the longest request URL servers have to accept
+	MaxURLLength = 1024
 )
 
+// Timeouts limits every phase of a request, zero value disables the limit
+type Timeouts struct {
+	Dial      time.Duration
+	Handshake time.Duration
+	Header    time.Duration
+	Body      time.Duration
+}
+
+var DefaultTimeouts = Timeouts{
+	Dial:      4 * time.Second,
+	Handshake: 4 * time.Second,
+	Header:    10 * time.Second,
+	Body:      60 * time.Second,
+}
+
 // Response represents a Gemini response
 type Response struct {
 	Status int
 	Meta   string
 	Body   []byte
+	// Code is the full two-digit status, Status is its first digit
+	Code int
+	// BodyReader is set instead of Body for successful streamed responses, see Client.Stream
+	BodyReader io.ReadCloser
+
+	// URL is the final requested URL, after following redirects
+	URL *url.URL
+	// Redirects lists URLs redirected from, in order, starting with the original one
+	Redirects []*url.URL
+	// Conn describes connection of the final request, nil if response was not received over network
+	Conn *ConnInfo
 }
 
 func NewResponse(status int, meta string, body []byte) *Response {
-	return &Response{status, meta, body}
+	return &Response{Status: status, Meta: meta, Body: body, Code: status * 10}
+}
+
+// NewResponseCode creates a response from the full two-digit status code
+func NewResponseCode(code int, meta string, body []byte) *Response {
+	return &Response{Status: code / 10, Meta: meta, Body: body, Code: code}
 }
 
 func NewResponseEmpty() *Response {
@@ -46,124 +82,152 @@ func NewResponseEmpty() *Response {
 }
 
 // GetFullGeminiLink normalizes raw link and ensures default port for Gemini
-// TODO check if canonical url logic can be unified
 func GetFullGeminiLink(linkRaw string) (*url.URL, error) {
-	if strings.HasPrefix(linkRaw, "http") {
-		return nil, fmt.Errorf("http(s) links aren't supported")
+	link, err := NormalizeURL(linkRaw)
+	if err != nil {
+		return nil, err
 	}
+	return WithDefaultPort(link), nil
+}
 
-	if !strings.HasPrefix(linkRaw, Protocol) {
-		linkRaw = Protocol + linkRaw
-	}
+// WithQuery percent-encodes user input as the whole query of link, as status 1x expects
+func WithQuery(link *url.URL, input string) (*url.URL, error) {
+	withInput := *link
+	withInput.RawQuery = strings.ReplaceAll(url.QueryEscape(input), "+", "%20")
+	withInput.Fragment = ""
 
-	link, err := url.Parse(linkRaw)
-	if err != nil {
-		return link, fmt.Errorf("error parsing URL: %w", err)
+	if length := len(withInput.String()); length > MaxURLLength {
+		return nil, fmt.Errorf("input is too long: request would be %d bytes, limit is %d", length, MaxURLLength)
 	}
+	return &withInput, nil
+}
+
+// DoRequest performs a Gemini request with redirect handling using DefaultClient
+func DoRequest(link *url.URL) (*Response, error) {
+	return DefaultClient.DoRequest(link)
+}
+
+// DoRequestContext performs a Gemini request with redirect handling using DefaultClient
+func DoRequestContext(ctx context.Context, link *url.URL) (*Response, error) {
+	return DefaultClient.DoRequestContext(ctx, link)
+}
+
+// deadlines sets per-phase deadlines of conn until request is cancelled,
+// so the past deadline set on cancellation is never replaced by a later phase
+type deadlines struct {
+	mu        sync.Mutex
+	conn      net.Conn
+	cancelled bool
+}
 
-	// Only add default port if no explicit port is present
-	if link.Port() == "" {
-		// Use JoinHostPort to be safe with IPv6 literals
-		hostname := link.Hostname()
-		if hostname == "" {
-			// Fallback to raw host if parsing failed for some reason
-			link.Host = link.Host + ":" + Port
-		} else {
-			link.Host = net.JoinHostPort(hostname, Port)
-		}
+// set sets conn deadline to timeout from now, zero timeout removes it
+func (d *deadlines) set(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancelled {
+		return
 	}
+	if timeout <= 0 {
+		_ = d.conn.SetDeadline(time.Time{})
+		return
+	}
+	_ = d.conn.SetDeadline(time.Now().Add(timeout))
+}
 
-	return link, nil
+// cancel unblocks any pending read or write and all later ones
+func (d *deadlines) cancel() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cancelled = true
+	_ = d.conn.SetDeadline(time.Unix(1, 0))
 }
 
-// DoRequest performs a Gemini request with redirect handling
-func DoRequest(link *url.URL) (*Response, error) {
-	redirectsLeft := MaxRedirects
-
-	for {
-		conn, err := GetConn(link.Host)
-		if err != nil {
-			return NewResponseEmpty(), fmt.Errorf("connection failed: %w", err)
-		}
-		defer conn.Close()
-
-		_, err = conn.Write([]byte(link.String() + "\r\n"))
-		if err != nil {
-			return NewResponseEmpty(), fmt.Errorf("sending request url failed: %w", err)
-		}
-
-		status, meta, body, err := GetResponse(conn)
-		if err != nil {
-			return NewResponse(status, meta, body), err
-		}
-
-		if status == StatusRedirect {
-			if redirectsLeft == 0 {
-				return NewResponse(status, meta, body), fmt.Errorf("too many redirects, last url: %s", meta)
-			}
-
-			link, err = GetFullGeminiLink(meta)
-			if err != nil {
-				return NewResponse(status, meta, body), fmt.Errorf("error generating gemini URL: %w", err)
-			}
-
-			redirectsLeft -= 1
-			continue
-		}
-
-		return NewResponse(status, meta, body), err
+// contextErr reports cancellation instead of the i/o error it caused
+func contextErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if errors.Is(err, ctx.Err()) {
+		return err
 	}
+	return fmt.Errorf("request cancelled: %w", ctx.Err())
 }
 
 // GetResponse reads and parses a Gemini response from a connection
 func GetResponse(conn io.Reader) (status int, meta string, body []byte, err error) {
 	reader := bufio.NewReader(conn)
 
-	// 20 text/gemini
-	// 20 text/gemini; charset=utf-8
-	responseHeader, err := reader.ReadString('\n')
-	if err != nil {
-		return status, meta, body, fmt.Errorf("response header read failed: %w", err)
+	code, meta, err := readHeader(reader)
+	if err != nil || code/10 != StatusSuccess {
+		return code / 10, meta, body, err
 	}
-	responseHeader = strings.TrimSpace(responseHeader)
-	// fmt.Println("responseHeader:", responseHeader) // suppress noisy output in library
 
-	statusDelim := strings.Index(responseHeader, " ")
+	body, err = io.ReadAll(reader)
+	if err != nil {
+		return code / 10, meta, body, fmt.Errorf("response body reading failed: %w", err)
+	}
+	return code / 10, meta, body, nil
+}
 
-	status, err = strconv.Atoi(responseHeader[0:1])
+// readHeader reads and validates response header, returning full two-digit status code
+func readHeader(reader *bufio.Reader) (code int, meta string, err error) {
+	// 20 text/gemini
+	// 20 text/gemini; charset=utf-8
+	responseHeader, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return code, meta, &HeaderError{Header: string(responseHeader), Err: fmt.Errorf("response header is too long")}
+	}
 	if err != nil {
-		return status, meta, body, fmt.Errorf("response code parsing failed: %w", err)
+		return code, meta, fmt.Errorf("response header read failed: %w", err)
 	}
 
-	meta = responseHeader[statusDelim+1:]
+	return parseHeader(string(responseHeader))
+}
 
-	switch status {
-	case StatusInput, StatusRedirect,
-		StatusTemporaryFailure, StatusPermanentFailure, StatusClientCertRequired:
-		return status, meta, body, nil
+// LimitReader returns a reader failing with ErrBodyTooLarge once r has more than max bytes,
+// the first max bytes are still returned. Non-positive max means no limit
+func LimitReader(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitedReader{reader: r, left: max}
+}
 
-	case StatusSuccess:
-		body, err := io.ReadAll(reader)
-		if err != nil {
-			return status, meta, body, fmt.Errorf("response body reading failed: %w", err)
-		}
+type limitedReader struct {
+	reader io.Reader
+	left   int64
+}
 
-		return status, meta, body, nil
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, ErrBodyTooLarge
+	}
 
-	default:
-		return status, meta, body, fmt.Errorf("unknown response status: %s", responseHeader)
+	// read one byte past the limit to find out whether there is more
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.reader.Read(p)
+	if int64(n) > l.left {
+		n = int(l.left)
+		l.left = -1
+		return n, ErrBodyTooLarge
 	}
+
+	l.left -= int64(n)
+	return n, err
 }
 
 // GetConn dials a TLS connection to the given address
 func GetConn(addr string) (io.ReadWriteCloser, error) {
-	dialer := &net.Dialer{Timeout: 4 * time.Second}
-
-	conn, err := tls.DialWithDialer(
-		dialer,
-		"tcp", addr,
-		&tls.Config{InsecureSkipVerify: true},
-	)
+	return GetConnContext(context.Background(), addr, DefaultTimeouts)
+}
 
+// GetConnContext dials a TLS connection to the given address,
+// applying dial and handshake timeouts separately
+func GetConnContext(ctx context.Context, addr string, timeouts Timeouts) (net.Conn, error) {
+	client := NewClient()
+	client.Timeouts = timeouts
+	conn, _, err := client.dial(ctx, addr, nil)
 	return conn, err
 }
@@ -2,10 +2,22 @@ package gemini
 
 import (
 	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 type errReader struct{}
@@ -45,3 +57,103 @@ func TestGetFullGeminiLinkInvalidURL(t *testing.T) {
 		t.Fatalf("expected URL parsing error, got %v", err)
 	}
 }
+
+// newTestCert generates a self-signed certificate for local test servers
+func newTestCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startStallingServer accepts TLS connections and never answers them
+func startStallingServer(t *testing.T) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{newTestCert(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDoRequestContextCancel(t *testing.T) {
+	addr := startStallingServer(t)
+	link, _ := url.Parse("gemini://" + addr + "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := DoRequestContext(ctx, link)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("request was not interrupted, took %v", elapsed)
+	}
+}
+
+func TestDeadlinesCancelWins(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// phase deadline set after cancellation must not extend it
+	d := &deadlines{conn: client}
+	d.cancel()
+	d.set(time.Hour)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("read was not interrupted by cancellation")
+	}
+}
+
+func TestDoRequestHeaderTimeout(t *testing.T) {
+	addr := startStallingServer(t)
+	link, _ := url.Parse("gemini://" + addr + "/")
+
+	client := NewClient()
+	client.Timeouts.Header = 100 * time.Millisecond
+	_, err := client.DoRequest(link)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected header read timeout, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+// Package geminitest provides in-memory servers for tests of gemini clients
+package geminitest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+)
+
+// Dialer serves every connection from memory instead of the network, for Client.DialTLSContext.
+// Responses are keyed by request line, e.g. "gemini://example.org:1965/", unknown requests get 51
+func Dialer(responses map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			request, err := bufio.NewReader(server).ReadString('\n')
+			if err != nil {
+				return
+			}
+			response, ok := responses[strings.TrimSpace(request)]
+			if !ok {
+				response = "51 not found\r\n"
+			}
+			_, _ = server.Write([]byte(response))
+		}()
+		return client, nil
+	}
+}
@@ -0,0 +1,274 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity is a client certificate presented to capsules under its URL prefixes
+type Identity struct {
+	Name        string
+	Certificate tls.Certificate
+	Prefixes    []string
+}
+
+// IdentityStore keeps identities in a directory: NAME.crt and NAME.key PEM files,
+// plus "scopes" file with "NAME PREFIX" lines
+type IdentityStore struct {
+	dir        string
+	identities map[string]*Identity
+	mu         sync.Mutex
+}
+
+var identityNameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// DefaultIdentitiesDir returns identities location in user config dir
+func DefaultIdentitiesDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "identities"), nil
+}
+
+// NewIdentityStore loads all identities from dir, missing dir means no identities yet
+func NewIdentityStore(dir string) (*IdentityStore, error) {
+	store := &IdentityStore{dir: dir, identities: make(map[string]*Identity)}
+
+	certPaths, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+	for _, certPath := range certPaths {
+		name := strings.TrimSuffix(filepath.Base(certPath), ".crt")
+		cert, err := tls.LoadX509KeyPair(certPath, filepath.Join(dir, name+".key"))
+		if err != nil {
+			return nil, fmt.Errorf("load identity %s: %w", name, err)
+		}
+		store.identities[name] = &Identity{Name: name, Certificate: cert}
+	}
+
+	if err := store.loadScopes(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *IdentityStore) scopesPath() string {
+	return filepath.Join(s.dir, "scopes")
+}
+
+func (s *IdentityStore) loadScopes() error {
+	file, err := os.Open(s.scopesPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("open identity scopes: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, prefix, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		if identity, exists := s.identities[name]; exists {
+			identity.Prefixes = append(identity.Prefixes, prefix)
+		}
+	}
+	return scanner.Err()
+}
+
+// saveScopes rewrites scopes file, caller holds the lock
+func (s *IdentityStore) saveScopes() error {
+	var b strings.Builder
+	for _, identity := range s.list() {
+		for _, prefix := range identity.Prefixes {
+			fmt.Fprintf(&b, "%s %s\n", identity.Name, prefix)
+		}
+	}
+
+	tempPath := s.scopesPath() + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, s.scopesPath())
+}
+
+// Generate creates a self-signed identity valid for the given duration
+func (s *IdentityStore) Generate(name string, validFor time.Duration) (*Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !identityNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid identity name: %q", name)
+	}
+	if _, exists := s.identities[name]; exists {
+		return nil, fmt.Errorf("identity already exists: %s", name)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("certificate creation failed: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(s.dir, name+".key"), keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, name+".crt"), certPEM, 0o644); err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	identity := &Identity{Name: name, Certificate: cert}
+	s.identities[name] = identity
+	return identity, nil
+}
+
+// Activate makes identity presented for every URL starting with link's prefix
+func (s *IdentityStore) Activate(name string, link *url.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[name]
+	if !ok {
+		return fmt.Errorf("no identity named %s", name)
+	}
+
+	prefix := identityPrefix(link)
+	// one identity per prefix, otherwise choice would be ambiguous
+	for _, other := range s.identities {
+		other.Prefixes = removePrefix(other.Prefixes, prefix)
+	}
+	identity.Prefixes = append(identity.Prefixes, prefix)
+	return s.saveScopes()
+}
+
+// Delete removes identity files and its scopes
+func (s *IdentityStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.identities[name]; !ok {
+		return fmt.Errorf("no identity named %s", name)
+	}
+	for _, ext := range []string{".crt", ".key"} {
+		if err := os.Remove(filepath.Join(s.dir, name+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	delete(s.identities, name)
+	return s.saveScopes()
+}
+
+// List returns identities sorted by name
+func (s *IdentityStore) List() []*Identity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *IdentityStore) list() []*Identity {
+	identities := make([]*Identity, 0, len(s.identities))
+	for _, identity := range s.identities {
+		identities = append(identities, identity)
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].Name < identities[j].Name })
+	return identities
+}
+
+// ForURL returns identity with the longest prefix matching link, or nil
+func (s *IdentityStore) ForURL(link *url.URL) *Identity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := identityPrefix(link)
+	var found *Identity
+	longest := 0
+	for _, identity := range s.identities {
+		for _, prefix := range identity.Prefixes {
+			if strings.HasPrefix(target, prefix) && len(prefix) > longest {
+				found = identity
+				longest = len(prefix)
+			}
+		}
+	}
+	return found
+}
+
+// identityPrefix formats link without default port, fragment and query
+func identityPrefix(link *url.URL) string {
+	host := strings.ToLower(link.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := link.Port(); port != "" && port != Port {
+		host += ":" + port
+	}
+
+	path := link.Path
+	if path == "" {
+		path = "/"
+	}
+	return Protocol + host + path
+}
+
+func removePrefix(prefixes []string, prefix string) []string {
+	kept := prefixes[:0]
+	for _, p := range prefixes {
+		if p != prefix {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,124 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIdentityStoreScopes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewIdentityStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Generate("plants", 24*time.Hour); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := store.Generate("station", 24*time.Hour); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := store.Generate("plants", 24*time.Hour); err == nil {
+		t.Fatalf("expected error for duplicate identity")
+	}
+	if _, err := store.Generate("../evil", 24*time.Hour); err == nil {
+		t.Fatalf("expected error for invalid name")
+	}
+
+	capsule, _ := url.Parse("gemini://astrobotany.mozz.us:1965/")
+	app, _ := url.Parse("gemini://astrobotany.mozz.us/app/")
+	if err := store.Activate("plants", capsule); err != nil {
+		t.Fatalf("activate: %v", err)
+	}
+	if err := store.Activate("station", app); err != nil {
+		t.Fatalf("activate: %v", err)
+	}
+
+	page, _ := url.Parse("gemini://astrobotany.mozz.us:1965/app/plant")
+	if identity := store.ForURL(page); identity == nil || identity.Name != "station" {
+		t.Fatalf("expected longest prefix identity, got %+v", identity)
+	}
+	other, _ := url.Parse("gemini://astrobotany.mozz.us/about")
+	if identity := store.ForURL(other); identity == nil || identity.Name != "plants" {
+		t.Fatalf("expected capsule identity, got %+v", identity)
+	}
+	unrelated, _ := url.Parse("gemini://example.org/")
+	if identity := store.ForURL(unrelated); identity != nil {
+		t.Fatalf("expected no identity, got %s", identity.Name)
+	}
+
+	// identities and scopes survive reload
+	reloaded, err := NewIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.List()) != 2 {
+		t.Fatalf("expected 2 identities, got %d", len(reloaded.List()))
+	}
+	if identity := reloaded.ForURL(page); identity == nil || identity.Name != "station" {
+		t.Fatalf("scopes not persisted, got %+v", identity)
+	}
+
+	if err := reloaded.Delete("station"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if identity := reloaded.ForURL(page); identity == nil || identity.Name != "plants" {
+		t.Fatalf("expected fallback to capsule identity, got %+v", identity)
+	}
+}
+
+func TestClientPresentsIdentity(t *testing.T) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{newTestCert(t)},
+		ClientAuth:   tls.RequestClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			_, _ = bufio.NewReader(tlsConn).ReadString('\n')
+			certs := tlsConn.ConnectionState().PeerCertificates
+			if len(certs) == 0 {
+				_, _ = tlsConn.Write([]byte("60 certificate required\r\n"))
+			} else {
+				_, _ = tlsConn.Write([]byte("20 text/plain\r\n" + certs[0].Subject.CommonName))
+			}
+			tlsConn.Close()
+		}
+	}()
+
+	store, err := NewIdentityStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient()
+	client.Identities = store
+
+	link, _ := url.Parse("gemini://" + listener.Addr().String() + "/private")
+	resp, err := client.DoRequest(link)
+	if err != nil || resp.Status != StatusClientCertRequired {
+		t.Fatalf("expected certificate request, got %+v, %v", resp, err)
+	}
+
+	if _, err := store.Generate("me", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Activate("me", link); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.DoRequest(link)
+	if err != nil || resp.Status != StatusSuccess || string(resp.Body) != "me" {
+		t.Fatalf("identity not presented: %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,98 @@
+package gemini
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// MediaType is the meta of a successful response split into type and parameters
+type MediaType struct {
+	// Type is lowercased, e.g. "text/gemini"
+	Type string
+	// Params have lowercased names, e.g. "charset" and "lang"
+	Params map[string]string
+}
+
+// ParseMediaType parses meta of a successful response, empty meta means text/gemini by spec.
+// Parameters are parsed leniently, as values like lang=en,fr are valid in gemini but not in MIME
+func ParseMediaType(meta string) (MediaType, error) {
+	if strings.TrimSpace(meta) == "" {
+		return MediaType{Type: GeminiMediaType, Params: map[string]string{}}, nil
+	}
+
+	typ, rest, _ := strings.Cut(meta, ";")
+	mediaType, _, err := mime.ParseMediaType(typ)
+	if err != nil {
+		return MediaType{}, fmt.Errorf("error parsing media type %q: %w", meta, err)
+	}
+
+	params := map[string]string{}
+	for _, param := range strings.Split(rest, ";") {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(param, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return MediaType{}, fmt.Errorf("error parsing media type %q: invalid parameter %q", meta, param)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		params[name] = value
+	}
+	return MediaType{Type: mediaType, Params: params}, nil
+}
+
+// Charset returns charset parameter, UTF-8 if absent
+func (m MediaType) Charset() string {
+	if charset := m.Params["charset"]; charset != "" {
+		return charset
+	}
+	return "utf-8"
+}
+
+// Lang returns lang parameter, empty if absent
+func (m MediaType) Lang() string {
+	return m.Params["lang"]
+}
+
+func (m MediaType) IsText() bool {
+	return strings.HasPrefix(m.Type, "text/")
+}
+
+func (m MediaType) IsGemtext() bool {
+	return m.Type == GeminiMediaType
+}
+
+// String formats media type back to meta
+func (m MediaType) String() string {
+	return mime.FormatMediaType(m.Type, m.Params)
+}
+
+// MediaType parses meta of a successful response
+func (r *Response) MediaType() (MediaType, error) {
+	return ParseMediaType(r.Meta)
+}
+
+// DecodeText converts text body to UTF-8 and returns media type with charset updated,
+// bodies of non-text types are returned unchanged
+func DecodeText(mediaType MediaType, body []byte) (MediaType, []byte, error) {
+	if !mediaType.IsText() || IsUTF8Charset(mediaType.Params["charset"]) {
+		return mediaType, body, nil
+	}
+
+	decoded, err := DecodeCharset(body, mediaType.Charset())
+	if err != nil {
+		return mediaType, body, err
+	}
+
+	params := make(map[string]string, len(mediaType.Params))
+	for name, value := range mediaType.Params {
+		params[name] = value
+	}
+	params["charset"] = "utf-8"
+	return MediaType{Type: mediaType.Type, Params: params}, decoded, nil
+}
@@ -0,0 +1,105 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseMediaType(t *testing.T) {
+	tests := []struct {
+		meta    string
+		typ     string
+		charset string
+		lang    string
+		err     bool
+	}{
+		{"Text/Gemini; charset=ISO-8859-1; LANG=de", "text/gemini", "ISO-8859-1", "de", false},
+		{"", "text/gemini", "utf-8", "", false},
+		{"text/gemini; lang=en,fr", "text/gemini", "utf-8", "en,fr", false},
+		{"text/plain;charset=\"koi8-r\" ; lang=ru;", "text/plain", "koi8-r", "ru", false},
+		{"text/gemini; charset", "", "", "", true},
+		{"text/gemini; =en", "", "", "", true},
+	}
+	for _, test := range tests {
+		mediaType, err := ParseMediaType(test.meta)
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error: %v", test.meta, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if mediaType.Type != test.typ || mediaType.Charset() != test.charset || mediaType.Lang() != test.lang {
+			t.Errorf("%q: unexpected media type: %+v", test.meta, mediaType)
+		}
+	}
+
+	mediaType, _ := ParseMediaType("text/gemini; lang=en,fr")
+	if !mediaType.IsGemtext() || !mediaType.IsText() {
+		t.Errorf("unexpected type: %+v", mediaType)
+	}
+	if again, err := ParseMediaType(mediaType.String()); err != nil || again.Lang() != "en,fr" {
+		t.Errorf("formatted meta %q is not parsed back: %+v, %v", mediaType, again, err)
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		charset string
+		body    []byte
+		want    string
+	}{
+		{"iso-8859-1", []byte{'c', 'a', 'f', 0xE9}, "café"},
+		{"Windows-1252", []byte{0x93, 'q', 0x94, ' ', 0x80}, "“q” €"},
+		{"KOI8-R", []byte{0xF0, 0xD2, 0xC9, 0xD7, 0xC5, 0xD4}, "Привет"},
+		{"windows-1251", []byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2}, "Привет"},
+		{"utf-8", []byte("Grüße"), "Grüße"},
+	}
+
+	for _, tt := range tests {
+		got, err := DecodeCharset(tt.body, tt.charset)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.charset, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.charset, tt.want, got)
+		}
+	}
+
+	var unsupported *UnsupportedCharsetError
+	if _, err := DecodeCharset([]byte("x"), "shift_jis"); !errors.As(err, &unsupported) {
+		t.Errorf("expected unsupported charset error, got %v", err)
+	}
+}
+
+func TestCharsetReaderSmallReads(t *testing.T) {
+	reader, err := NewCharsetReader(iotest.OneByteReader(strings.NewReader("\xE0 la carte \xE9t\xE9")), "latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(iotest.OneByteReader(reader))
+	if err != nil || string(got) != "à la carte été" {
+		t.Fatalf("unexpected result: %q, %v", got, err)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	mediaType, _ := ParseMediaType("text/gemini; charset=koi8-r; lang=ru")
+	decoded, body, err := DecodeText(mediaType, []byte{0xF0, 0xD2, 0xC9, 0xD7, 0xC5, 0xD4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "Привет" || decoded.Charset() != "utf-8" || decoded.Lang() != "ru" {
+		t.Fatalf("unexpected result: %+v %q", decoded, body)
+	}
+	if mediaType.Charset() != "koi8-r" {
+		t.Fatalf("original media type modified: %+v", mediaType)
+	}
+	if decoded.String() != "text/gemini; charset=utf-8; lang=ru" {
+		t.Fatalf("unexpected meta: %s", decoded)
+	}
+}
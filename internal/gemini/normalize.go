@@ -0,0 +1,244 @@
+package gemini
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var schemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// NormalizeURL parses raw link and normalizes it with Normalize,
+// links without scheme are treated as gemini ones
+func NormalizeURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "//") {
+		raw = "gemini:" + raw
+	} else if !schemeRe.MatchString(raw) {
+		raw = Protocol + raw
+	}
+
+	link, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %w", err)
+	}
+	return Normalize(link)
+}
+
+// Normalize returns canonical form of a gemini URL, so the same page always has the same URL:
+// lowercased host in IDNA (punycode) form, no default port, userinfo or fragment,
+// dot segments removed, percent-encoding normalized and empty path replaced with "/"
+func Normalize(link *url.URL) (*url.URL, error) {
+	if link.Scheme != "gemini" {
+		return nil, fmt.Errorf("unsupported scheme: %s", link.Scheme)
+	}
+	if link.User != nil {
+		return nil, fmt.Errorf("userinfo is not allowed in gemini URLs: %s", link.Redacted())
+	}
+	if link.Opaque != "" {
+		return nil, fmt.Errorf("missing host in URL: %s", link)
+	}
+
+	host, err := normalizeHost(link.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if port := link.Port(); port != "" && port != Port {
+		host += ":" + port
+	}
+
+	path, err := normalizeEscapes(link.EscapedPath(), isPathByte)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL path: %w", err)
+	}
+	path = removeDotSegments(path)
+	if path == "" {
+		path = "/"
+	}
+	unescapedPath, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL path: %w", err)
+	}
+
+	query, err := normalizeEscapes(link.RawQuery, isQueryByte)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL query: %w", err)
+	}
+
+	return &url.URL{
+		Scheme:   "gemini",
+		Host:     host,
+		Path:     unescapedPath,
+		RawPath:  path,
+		RawQuery: query,
+	}, nil
+}
+
+// CanonicalString returns normalized link as string, or link as is if it cannot be normalized
+func CanonicalString(link *url.URL) string {
+	normalized, err := Normalize(link)
+	if err != nil {
+		return link.String()
+	}
+	return normalized.String()
+}
+
+// WithDefaultPort returns copy of link with explicit port, as used in requests
+func WithDefaultPort(link *url.URL) *url.URL {
+	withPort := *link
+	if link.Port() == "" {
+		host := link.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		withPort.Host = host + ":" + Port
+	}
+	return &withPort
+}
+
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("missing host in URL")
+	}
+
+	// IPv6 literal
+	if strings.Contains(host, ":") {
+		return "[" + strings.ToLower(host) + "]", nil
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	if isASCII(host) {
+		host = strings.ToLower(host)
+	} else {
+		var err error
+		if host, err = toASCIIHost(host); err != nil {
+			return "", err
+		}
+	}
+
+	// hosts like ".." would also escape directories named after them
+	for _, label := range strings.Split(host, ".") {
+		if label == "" {
+			return "", fmt.Errorf("invalid host name %q: empty label", host)
+		}
+	}
+	return host, nil
+}
+
+// toASCIIHost converts internationalized host name to IDNA form,
+// lowercasing labels and treating ideographic full stops as dots
+func toASCIIHost(host string) (string, error) {
+	if !utf8.ValidString(host) {
+		return "", fmt.Errorf("invalid host name: %q", host)
+	}
+
+	host = strings.NewReplacer("。", ".", "．", ".", "｡", ".").Replace(host)
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+
+		encoded, err := punycodeEncode(label)
+		if err != nil {
+			return "", fmt.Errorf("invalid host name %q: %w", host, err)
+		}
+		labels[i] = "xn--" + encoded
+		if len(labels[i]) > 63 {
+			return "", fmt.Errorf("invalid host name %q: label too long", host)
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeEscapes uppercases percent-encodings, decodes unreserved characters
+// and encodes bytes not allowed in the URL component
+func normalizeEscapes(s string, allowed func(c byte) bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' {
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", fmt.Errorf("invalid escape %q", s[i:min(i+3, len(s))])
+			}
+			c = unhex(s[i+1])<<4 | unhex(s[i+2])
+			i += 2
+			if isUnreserved(c) {
+				b.WriteByte(c)
+				continue
+			}
+		} else if allowed(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String(), nil
+}
+
+// removeDotSegments resolves "." and ".." segments as in RFC 3986 section 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+
+	segments := strings.Split(path[1:], "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		// keep trailing slash, e.g. "/a/b/.." becomes "/a/"
+		if last {
+			out = append(out, "")
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isPathByte(c byte) bool {
+	return isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/", c) >= 0
+}
+
+func isQueryByte(c byte) bool {
+	return isPathByte(c) || c == '?'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
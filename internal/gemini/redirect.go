@@ -0,0 +1,58 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrUseLastResponse returned by Client.CheckRedirect stops following redirects
+	// without an error, the redirect response itself is returned
+	ErrUseLastResponse = errors.New("use last response")
+	ErrRedirectLoop    = errors.New("redirect loop")
+	// ErrRedirectRefused wraps redirects refused by policy: other schemes, too many redirects
+	// and errors returned by Client.CheckRedirect
+	ErrRedirectRefused = errors.New("redirect refused")
+)
+
+// Redirect describes a redirect about to be followed
+type Redirect struct {
+	From      *url.URL
+	To        *url.URL
+	Permanent bool
+	// Via lists every requested URL so far, starting with the original one
+	Via []*url.URL
+}
+
+func (r Redirect) CrossHost() bool {
+	return !strings.EqualFold(r.From.Host, r.To.Host)
+}
+
+func (r Redirect) CrossScheme() bool {
+	return r.From.Scheme != r.To.Scheme
+}
+
+// SameHostRedirects is a redirect policy returning cross-host redirects to the caller
+func SameHostRedirects(redirect Redirect) error {
+	if redirect.CrossHost() || redirect.CrossScheme() {
+		return ErrUseLastResponse
+	}
+	return nil
+}
+
+// resolveRedirect resolves redirect target relative to the requested URL,
+// gemini targets get default port like GetFullGeminiLink
+func resolveRedirect(from *url.URL, meta string) (*url.URL, error) {
+	ref, err := url.Parse(strings.TrimSpace(meta))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing redirect URL: %w", err)
+	}
+
+	target := from.ResolveReference(ref)
+	if target.Scheme != "gemini" {
+		return target, nil
+	}
+	return GetFullGeminiLink(target.String())
+}
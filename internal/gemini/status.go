@@ -0,0 +1,145 @@
+package gemini
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// full two-digit codes, see Response.Code
+const (
+	CodeInput          = 10
+	CodeSensitiveInput = 11
+
+	CodeSuccess = 20
+
+	CodeRedirectTemporary = 30
+	CodeRedirectPermanent = 31
+
+	CodeTemporaryFailure  = 40
+	CodeServerUnavailable = 41
+	CodeCGIError          = 42
+	CodeProxyError        = 43
+	CodeSlowDown          = 44
+
+	CodePermanentFailure    = 50
+	CodeNotFound            = 51
+	CodeGone                = 52
+	CodeProxyRequestRefused = 53
+	CodeBadRequest          = 59
+
+	CodeClientCertRequired = 60
+	CodeCertNotAuthorised  = 61
+	CodeCertNotValid       = 62
+
+	// MaxMetaLength is the longest meta allowed in response header
+	MaxMetaLength = 1024
+)
+
+var statusText = map[int]string{
+	CodeInput:               "INPUT",
+	CodeSensitiveInput:      "SENSITIVE INPUT",
+	CodeSuccess:             "SUCCESS",
+	CodeRedirectTemporary:   "REDIRECT - TEMPORARY",
+	CodeRedirectPermanent:   "REDIRECT - PERMANENT",
+	CodeTemporaryFailure:    "TEMPORARY FAILURE",
+	CodeServerUnavailable:   "SERVER UNAVAILABLE",
+	CodeCGIError:            "CGI ERROR",
+	CodeProxyError:          "PROXY ERROR",
+	CodeSlowDown:            "SLOW DOWN",
+	CodePermanentFailure:    "PERMANENT FAILURE",
+	CodeNotFound:            "NOT FOUND",
+	CodeGone:                "GONE",
+	CodeProxyRequestRefused: "PROXY REQUEST REFUSED",
+	CodeBadRequest:          "BAD REQUEST",
+	CodeClientCertRequired:  "CLIENT CERTIFICATE REQUIRED",
+	CodeCertNotAuthorised:   "CERTIFICATE NOT AUTHORISED",
+	CodeCertNotValid:        "CERTIFICATE NOT VALID",
+}
+
+// StatusText returns spec name of the code, unknown codes fall back to their class name
+func StatusText(code int) string {
+	if text, ok := statusText[code]; ok {
+		return text
+	}
+	return statusText[code/10*10]
+}
+
+// StatusError is a failure response: 4x, 5x or 6x
+type StatusError struct {
+	Code int
+	Meta string
+}
+
+func (e *StatusError) Error() string {
+	if e.Meta == "" {
+		return fmt.Sprintf("%d %s", e.Code, StatusText(e.Code))
+	}
+	return fmt.Sprintf("%d %s: %s", e.Code, StatusText(e.Code), e.Meta)
+}
+
+// Temporary reports whether the same request may succeed later
+func (e *StatusError) Temporary() bool {
+	return e.Code/10 == StatusTemporaryFailure
+}
+
+// Err returns StatusError for failure responses, nil otherwise
+func (r *Response) Err() error {
+	switch r.Status {
+	case StatusTemporaryFailure, StatusPermanentFailure, StatusClientCertRequired:
+		return &StatusError{Code: r.Code, Meta: r.Meta}
+	default:
+		return nil
+	}
+}
+
+// HeaderError reports a malformed response header
+type HeaderError struct {
+	Header string
+	Err    error
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("%v, header %q", e.Err, e.Header)
+}
+
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// parseHeader validates "<STATUS><SPACE><META><CR><LF>" header line
+func parseHeader(line string) (code int, meta string, err error) {
+	headerErr := func(err error) (int, string, error) {
+		return code, meta, &HeaderError{Header: line, Err: err}
+	}
+
+	header, ok := strings.CutSuffix(line, "\r\n")
+	if !ok {
+		return headerErr(fmt.Errorf("response header must end with CRLF"))
+	}
+
+	if len(header) < 2 {
+		return headerErr(fmt.Errorf("response code parsing failed: too short"))
+	}
+	code, err = strconv.Atoi(header[0:2])
+	if err != nil || header[0] == '+' || header[0] == '-' {
+		return headerErr(fmt.Errorf("response code parsing failed: %q", header[0:2]))
+	}
+
+	if status := code / 10; status < StatusInput || status > StatusClientCertRequired {
+		return headerErr(fmt.Errorf("unknown response status: %d", code))
+	}
+
+	rest := header[2:]
+	if rest != "" {
+		meta, ok = strings.CutPrefix(rest, " ")
+		if !ok {
+			return headerErr(fmt.Errorf("response code must be followed by a space"))
+		}
+	}
+
+	if len(meta) > MaxMetaLength {
+		return headerErr(fmt.Errorf("meta is longer than %d bytes", MaxMetaLength))
+	}
+	return code, meta, nil
+}
@@ -0,0 +1,77 @@
+package gemini
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		line   string
+		code   int
+		meta   string
+		errMsg string
+	}{
+		{"20 text/gemini\r\n", CodeSuccess, "text/gemini", ""},
+		{"31 gemini://example.org/\r\n", CodeRedirectPermanent, "gemini://example.org/", ""},
+		{"44 30\r\n", CodeSlowDown, "30", ""},
+		{"51\r\n", CodeNotFound, "", ""},
+		{"20 text/gemini\n", 0, "", "CRLF"},
+		{"20text/gemini\r\n", 0, "", "followed by a space"},
+		{"2 text/gemini\r\n", 0, "", "response code parsing failed"},
+		{"-1 meta\r\n", 0, "", "response code parsing failed"},
+		{"70 meta\r\n", 0, "", "unknown response status"},
+		{"20 " + strings.Repeat("m", MaxMetaLength+1) + "\r\n", 0, "", "meta is longer"},
+	}
+
+	for _, tt := range tests {
+		code, meta, err := parseHeader(tt.line)
+		if tt.errMsg != "" {
+			var headerErr *HeaderError
+			if !errors.As(err, &headerErr) || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("%q: expected header error with %q, got %v", tt.line, tt.errMsg, err)
+			}
+			continue
+		}
+		if err != nil || code != tt.code || meta != tt.meta {
+			t.Errorf("%q: got %d %q %v", tt.line, code, meta, err)
+		}
+	}
+}
+
+func TestGetResponseHeaderTooLong(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("20 " + strings.Repeat("m", 8192) + "\r\n"))
+	_, _, _, err := GetResponse(reader)
+	var headerErr *HeaderError
+	if !errors.As(err, &headerErr) {
+		t.Fatalf("expected header error, got %v", err)
+	}
+}
+
+func TestResponseErr(t *testing.T) {
+	if err := NewResponseCode(CodeSuccess, "text/gemini", nil).Err(); err != nil {
+		t.Fatalf("success should not be an error: %v", err)
+	}
+	if err := NewResponseCode(CodeRedirectTemporary, "/new", nil).Err(); err != nil {
+		t.Fatalf("redirect should not be an error: %v", err)
+	}
+
+	err := NewResponseCode(CodeSlowDown, "10", nil).Err()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || !statusErr.Temporary() || statusErr.Code != CodeSlowDown {
+		t.Fatalf("expected temporary status error, got %v", err)
+	}
+	if err.Error() != "44 SLOW DOWN: 10" {
+		t.Errorf("unexpected message: %s", err)
+	}
+
+	err = NewResponseCode(CodeNotFound, "", nil).Err()
+	if !errors.As(err, &statusErr) || statusErr.Temporary() {
+		t.Fatalf("expected permanent status error, got %v", err)
+	}
+	if StatusText(57) != StatusText(CodePermanentFailure) {
+		t.Errorf("unknown code should fall back to class name, got %q", StatusText(57))
+	}
+}
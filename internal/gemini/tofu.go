@@ -0,0 +1,184 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownHost is a certificate pinned on first use
+type KnownHost struct {
+	// Fingerprint is hex encoded sha256 of the certificate
+	Fingerprint string
+	// Expires is NotAfter of the certificate, pin can be rotated afterwards
+	Expires time.Time
+}
+
+// KnownHosts stores pinned certificates per host:port
+type KnownHosts interface {
+	Lookup(addr string) (KnownHost, bool)
+	Trust(addr string, host KnownHost) error
+}
+
+// Trust tells how server certificate was checked against pinned ones
+type Trust int
+
+const (
+	// TrustUnchecked means certificates are not pinned or connection has no certificate
+	TrustUnchecked Trust = iota
+	// TrustPinned means host was seen for the first time and its certificate is pinned now
+	TrustPinned
+	// TrustKnown means certificate matches the pinned one
+	TrustKnown
+	// TrustRotated means pinned certificate has expired and was replaced with the presented one
+	TrustRotated
+)
+
+func (t Trust) String() string {
+	switch t {
+	case TrustPinned:
+		return "first use, certificate pinned"
+	case TrustKnown:
+		return "matches pinned certificate"
+	case TrustRotated:
+		return "pinned certificate expired, replaced"
+	}
+	return "not checked"
+}
+
+// CertMismatchError reports a certificate differing from the pinned one
+type CertMismatchError struct {
+	Addr      string
+	Known     KnownHost
+	Presented KnownHost
+}
+
+func (e *CertMismatchError) Error() string {
+	return fmt.Sprintf("certificate mismatch for %s: known %s (expires %s), presented %s (expires %s)",
+		e.Addr,
+		e.Known.Fingerprint, e.Known.Expires.Format(time.DateOnly),
+		e.Presented.Fingerprint, e.Presented.Expires.Format(time.DateOnly))
+}
+
+func NewKnownHost(cert *x509.Certificate) KnownHost {
+	return KnownHost{Fingerprint: Fingerprint(cert), Expires: cert.NotAfter.UTC()}
+}
+
+// Fingerprint returns hex encoded sha256 of the certificate
+func Fingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// verifyKnownHost trusts unknown hosts, and replaces pins whose certificate has already expired
+func verifyKnownHost(store KnownHosts, addr string, cert *x509.Certificate, now time.Time) (Trust, error) {
+	presented := NewKnownHost(cert)
+
+	known, ok := store.Lookup(addr)
+	if ok && known.Fingerprint == presented.Fingerprint {
+		return TrustKnown, nil
+	}
+
+	if ok && now.Before(known.Expires) {
+		return TrustUnchecked, &CertMismatchError{Addr: addr, Known: known, Presented: presented}
+	}
+
+	if err := store.Trust(addr, presented); err != nil {
+		return TrustUnchecked, fmt.Errorf("storing known host failed: %w", err)
+	}
+	if ok {
+		return TrustRotated, nil
+	}
+	return TrustPinned, nil
+}
+
+// FileKnownHosts keeps pins in a text file, one "host:port fingerprint expires" per line.
+// New pins are appended, the last line for a host wins
+type FileKnownHosts struct {
+	path  string
+	hosts map[string]KnownHost
+	mu    sync.Mutex
+}
+
+// DefaultKnownHostsPath returns known hosts location in user config dir
+func DefaultKnownHostsPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "known_hosts"), nil
+}
+
+// NewFileKnownHosts loads known hosts from path, missing file means no pins yet
+func NewFileKnownHosts(path string) (*FileKnownHosts, error) {
+	store := &FileKnownHosts{path: path, hosts: make(map[string]KnownHost)}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("open known hosts: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed known hosts line: %q", line)
+		}
+		expires, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed known hosts expiry: %w", err)
+		}
+		store.hosts[fields[0]] = KnownHost{Fingerprint: fields[1], Expires: expires}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan known hosts: %w", err)
+	}
+	return store, nil
+}
+
+func (s *FileKnownHosts) Lookup(addr string) (KnownHost, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host, ok := s.hosts[addr]
+	return host, ok
+}
+
+func (s *FileKnownHosts) Trust(addr string, host KnownHost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line := fmt.Sprintf("%s %s %s\n", addr, host.Fingerprint, host.Expires.UTC().Format(time.RFC3339))
+	if _, err := file.WriteString(line); err != nil {
+		return err
+	}
+
+	s.hosts[addr] = host
+	return nil
+}
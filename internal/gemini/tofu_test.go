@@ -0,0 +1,81 @@
+package gemini
+
+import (
+	"crypto/x509"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func parseTestCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+	cert, err := x509.ParseCertificate(newTestCert(t).Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifyKnownHostTrustOnFirstUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	store, err := NewFileKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := parseTestCert(t)
+	if trust, err := verifyKnownHost(store, "example.org:1965", first, time.Now()); err != nil || trust != TrustPinned {
+		t.Fatalf("first use should be trusted: %v %v", trust, err)
+	}
+	if trust, err := verifyKnownHost(store, "example.org:1965", first, time.Now()); err != nil || trust != TrustKnown {
+		t.Fatalf("pinned certificate should be accepted: %v %v", trust, err)
+	}
+
+	// pins survive reload
+	reloaded, err := NewFileKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known, ok := reloaded.Lookup("example.org:1965")
+	if !ok || known.Fingerprint != Fingerprint(first) {
+		t.Fatalf("pin not persisted: %+v", known)
+	}
+
+	second := parseTestCert(t)
+	_, err = verifyKnownHost(reloaded, "example.org:1965", second, time.Now())
+	var mismatch *CertMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+	if mismatch.Presented.Fingerprint != Fingerprint(second) {
+		t.Fatalf("mismatch should report presented certificate: %+v", mismatch)
+	}
+
+	// different port is a different host
+	if _, err := verifyKnownHost(reloaded, "example.org:1966", second, time.Now()); err != nil {
+		t.Fatalf("other port should be trusted on first use: %v", err)
+	}
+}
+
+func TestVerifyKnownHostRotatesExpiredPin(t *testing.T) {
+	store, err := NewFileKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := parseTestCert(t)
+	if _, err := verifyKnownHost(store, "example.org:1965", first, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	second := parseTestCert(t)
+	afterExpiry := first.NotAfter.Add(time.Minute)
+	if trust, err := verifyKnownHost(store, "example.org:1965", second, afterExpiry); err != nil || trust != TrustRotated {
+		t.Fatalf("expired pin should be rotated: %v %v", trust, err)
+	}
+	known, _ := store.Lookup("example.org:1965")
+	if known.Fingerprint != Fingerprint(second) {
+		t.Fatalf("pin not rotated: %+v", known)
+	}
+}
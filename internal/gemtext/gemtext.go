@@ -0,0 +1,205 @@
+// Package gemtext parses text/gemini documents into typed lines and serializes them back
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	LinkPrefix         = "=>"
+	HeadingPrefix      = "#"
+	ListItemPrefix     = "* "
+	QuotePrefix        = ">"
+	PreformattedToggle = "```"
+)
+
+// Line is one of Text, Link, Heading, ListItem, Quote or Preformatted
+type Line interface {
+	// String formats line back to gemtext
+	String() string
+}
+
+type Text string
+
+type Link struct {
+	URL string
+	// Label is empty if link has no user-friendly name
+	Label string
+}
+
+type Heading struct {
+	// Level is 1 to 3
+	Level int
+	Text  string
+}
+
+type ListItem string
+
+type Quote string
+
+// Preformatted is a whole block between toggle lines
+type Preformatted struct {
+	Alt   string
+	Lines []string
+}
+
+// Document is a parsed gemtext body
+type Document []Line
+
+func (t Text) String() string { return string(t) }
+
+func (l Link) String() string {
+	if l.Label == "" {
+		return LinkPrefix + " " + l.URL
+	}
+	return LinkPrefix + " " + l.URL + " " + l.Label
+}
+
+// Name returns label, or URL if link has no label
+func (l Link) Name() string {
+	if l.Label == "" {
+		return l.URL
+	}
+	return l.Label
+}
+
+// Resolve returns link URL resolved against base
+func (l Link) Resolve(base *url.URL) (*url.URL, error) {
+	ref, err := url.Parse(l.URL)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return ref, nil
+	}
+	return base.ResolveReference(ref), nil
+}
+
+func (h Heading) String() string {
+	return strings.Repeat(HeadingPrefix, h.Level) + " " + h.Text
+}
+
+func (i ListItem) String() string { return ListItemPrefix + string(i) }
+
+func (q Quote) String() string { return QuotePrefix + " " + string(q) }
+
+func (p Preformatted) String() string {
+	var b strings.Builder
+	b.WriteString(PreformattedToggle + p.Alt + "\n")
+	for _, line := range p.Lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(PreformattedToggle)
+	return b.String()
+}
+
+// Parse reads gemtext document, unterminated preformatted block ends with the document
+func Parse(r io.Reader) (Document, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var doc Document
+	var block *Preformatted
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, PreformattedToggle) {
+			if block == nil {
+				block = &Preformatted{Alt: strings.TrimSpace(line[len(PreformattedToggle):])}
+			} else {
+				doc = append(doc, *block)
+				block = nil
+			}
+			continue
+		}
+
+		if block != nil {
+			block.Lines = append(block.Lines, line)
+			continue
+		}
+		doc = append(doc, ParseLine(line))
+	}
+	if block != nil {
+		doc = append(doc, *block)
+	}
+
+	return doc, scanner.Err()
+}
+
+// ParseString parses gemtext document held in memory
+func ParseString(body string) Document {
+	// reading from strings.Reader cannot fail, lines are limited by the body size
+	doc, _ := Parse(strings.NewReader(body))
+	return doc
+}
+
+// ParseLine parses single line outside of preformatted blocks,
+// link line without URL is treated as text
+func ParseLine(line string) Line {
+	switch {
+	case strings.HasPrefix(line, LinkPrefix):
+		fields := strings.Fields(line[len(LinkPrefix):])
+		if len(fields) == 0 {
+			return Text(line)
+		}
+		rest := strings.TrimSpace(line[len(LinkPrefix):])
+		label := strings.TrimSpace(rest[len(fields[0]):])
+		return Link{URL: fields[0], Label: label}
+
+	case strings.HasPrefix(line, HeadingPrefix):
+		level := 1
+		for level < 3 && strings.HasPrefix(line[level:], HeadingPrefix) {
+			level++
+		}
+		return Heading{Level: level, Text: strings.TrimSpace(line[level:])}
+
+	case strings.HasPrefix(line, ListItemPrefix):
+		return ListItem(strings.TrimSpace(line[len(ListItemPrefix):]))
+
+	case strings.HasPrefix(line, QuotePrefix):
+		return Quote(strings.TrimSpace(line[len(QuotePrefix):]))
+	}
+	return Text(line)
+}
+
+// Links returns all link lines of the document
+func (d Document) Links() []Link {
+	var links []Link
+	for _, line := range d {
+		if link, ok := line.(Link); ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// Title returns text of the first top level heading, or of the first heading if there is none
+func (d Document) Title() string {
+	title := ""
+	for _, line := range d {
+		heading, ok := line.(Heading)
+		if !ok {
+			continue
+		}
+		if heading.Level == 1 {
+			return heading.Text
+		}
+		if title == "" {
+			title = heading.Text
+		}
+	}
+	return title
+}
+
+// String serializes document back to gemtext
+func (d Document) String() string {
+	var b strings.Builder
+	for _, line := range d {
+		b.WriteString(line.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
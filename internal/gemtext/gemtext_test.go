@@ -0,0 +1,105 @@
+package gemtext
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	body := "# Title\r\n" +
+		"##Sub\n" +
+		"#### deep\n" +
+		"plain text\n" +
+		"=>\n" +
+		"=> /docs\n" +
+		"=>\tgemini://example.org/   Example   site \n" +
+		"* item\n" +
+		"*not an item\n" +
+		">quoted\n" +
+		"``` ascii art\n" +
+		"=> /not-a-link\n" +
+		"# not a heading\n" +
+		"```\n" +
+		"```\n" +
+		"unterminated"
+
+	doc, err := Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	want := Document{
+		Heading{Level: 1, Text: "Title"},
+		Heading{Level: 2, Text: "Sub"},
+		Heading{Level: 3, Text: "# deep"},
+		Text("plain text"),
+		Text("=>"),
+		Link{URL: "/docs"},
+		Link{URL: "gemini://example.org/", Label: "Example   site"},
+		ListItem("item"),
+		Text("*not an item"),
+		Quote("quoted"),
+		Preformatted{Alt: "ascii art", Lines: []string{"=> /not-a-link", "# not a heading"}},
+		Preformatted{Lines: []string{"unterminated"}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("unexpected document:\n%#v\nwant:\n%#v", doc, want)
+	}
+}
+
+func TestDocumentString(t *testing.T) {
+	body := "# Title\n" +
+		"text\n" +
+		"\n" +
+		"=> /docs Docs\n" +
+		"=> /raw\n" +
+		"* item\n" +
+		"> quote\n" +
+		"```alt\n" +
+		"  code\n" +
+		"```\n"
+
+	doc := ParseString(body)
+	if got := doc.String(); got != body {
+		t.Fatalf("round trip mismatch:\n%q\nwant:\n%q", got, body)
+	}
+	if again := ParseString(doc.String()); !reflect.DeepEqual(again, doc) {
+		t.Fatalf("reparsed document differs: %#v", again)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	doc := ParseString("=> a A\ntext\n```\n=> b\n```\n=> gemini://example.org/c\n")
+	links := doc.Links()
+	if len(links) != 2 || links[0].Name() != "A" || links[1].Name() != "gemini://example.org/c" {
+		t.Fatalf("unexpected links: %+v", links)
+	}
+
+	base, _ := url.Parse("gemini://example.org/dir/page.gmi")
+	resolved, err := links[0].Resolve(base)
+	if err != nil || resolved.String() != "gemini://example.org/dir/a" {
+		t.Fatalf("unexpected resolved link: %v, %v", resolved, err)
+	}
+
+	if _, err := (Link{URL: "gemini://%zz"}).Resolve(base); err == nil {
+		t.Fatalf("expected error for malformed URL")
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"## Sub\n# Main\n", "Main"},
+		{"text\n### Small\n## Sub\n", "Small"},
+		{"no headings\n", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseString(tt.body).Title(); got != tt.want {
+			t.Errorf("Title(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
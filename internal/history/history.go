@@ -0,0 +1,170 @@
+// Package history keeps back/forward navigation of a browsing session and a persistent log of visited pages
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stack is back/forward navigation of one session. Visiting a page drops pages ahead of the current one
+type Stack struct {
+	entries []string
+	// current is index of the shown page, -1 if none
+	current int
+}
+
+func NewStack() *Stack {
+	return &Stack{current: -1}
+}
+
+// Visit adds link after the current page and makes it current, reloading current page changes nothing
+func (s *Stack) Visit(link string) {
+	if s.current >= 0 && s.entries[s.current] == link {
+		return
+	}
+	s.entries = append(s.entries[:s.current+1], link)
+	s.current++
+}
+
+// At returns entry offset from the current one, e.g. -1 for back and 1 for forward
+func (s *Stack) At(offset int) (string, bool) {
+	index := s.current + offset
+	if s.current < 0 || index < 0 || index >= len(s.entries) {
+		return "", false
+	}
+	return s.entries[index], true
+}
+
+// Move makes entry offset from the current one current. It is called once the page is shown,
+// so a failed request keeps the position
+func (s *Stack) Move(offset int) bool {
+	if _, ok := s.At(offset); !ok {
+		return false
+	}
+	s.current += offset
+	return true
+}
+
+// Entries returns all pages of the session, oldest first
+func (s *Stack) Entries() []string {
+	return s.entries
+}
+
+// Current returns index of the current entry, -1 if no page is shown yet
+func (s *Stack) Current() int {
+	return s.current
+}
+
+// Entry is a visit recorded in Log
+type Entry struct {
+	Time  time.Time
+	URL   string
+	Title string
+}
+
+// Log is a persistent global history. It is a text file with one "time\turl\ttitle" line per visit,
+// new visits are appended
+type Log struct {
+	path    string
+	entries []Entry
+	mu      sync.Mutex
+}
+
+// DefaultLogPath returns history location in user config dir
+func DefaultLogPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gemini-tools", "history"), nil
+}
+
+// NewLog loads history from path, missing file means nothing is visited yet
+func NewLog(path string) (*Log, error) {
+	log := &Log{path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return log, nil
+		}
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed history line: %q", line)
+		}
+		visited, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed history time: %w", err)
+		}
+		log.entries = append(log.entries, Entry{Time: visited, URL: fields[1], Title: fields[2]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan history: %w", err)
+	}
+	return log, nil
+}
+
+// Add records visit of link with title at time t
+func (l *Log) Add(link, title string, t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// title comes from the page, it must not break the line format
+	title = strings.Join(strings.Fields(title), " ")
+	entry := Entry{Time: t.UTC().Truncate(time.Second), URL: link, Title: title}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.URL, entry.Title)
+	if _, err := file.WriteString(line); err != nil {
+		return err
+	}
+
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Search returns visits whose URL or title contains query ignoring case, newest first.
+// Empty query matches everything, limit 0 means no limit
+func (l *Log) Search(query string, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	query = strings.ToLower(query)
+	var found []Entry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if strings.Contains(strings.ToLower(entry.URL), query) || strings.Contains(strings.ToLower(entry.Title), query) {
+			found = append(found, entry)
+			if len(found) == limit {
+				break
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStack(t *testing.T) {
+	s := NewStack()
+	if _, ok := s.At(-1); ok {
+		t.Fatalf("empty stack should have no back entry")
+	}
+
+	for _, link := range []string{"a", "b", "b", "c"} {
+		s.Visit(link)
+	}
+	if !reflect.DeepEqual(s.Entries(), []string{"a", "b", "c"}) || s.Current() != 2 {
+		t.Fatalf("unexpected entries: %v, current %d", s.Entries(), s.Current())
+	}
+
+	if back, ok := s.At(-1); !ok || back != "b" {
+		t.Fatalf("unexpected back entry: %q %v", back, ok)
+	}
+	if s.Current() != 2 {
+		t.Fatalf("At should not move")
+	}
+
+	if !s.Move(-2) || s.Current() != 0 {
+		t.Fatalf("move back failed, current %d", s.Current())
+	}
+	if s.Move(-1) {
+		t.Fatalf("move before the first entry should fail")
+	}
+	if forward, ok := s.At(1); !ok || forward != "b" {
+		t.Fatalf("unexpected forward entry: %q %v", forward, ok)
+	}
+
+	// new page drops forward entries
+	s.Visit("d")
+	if !reflect.DeepEqual(s.Entries(), []string{"a", "d"}) || s.Current() != 1 {
+		t.Fatalf("unexpected entries after visit: %v, current %d", s.Entries(), s.Current())
+	}
+	if _, ok := s.At(1); ok {
+		t.Fatalf("forward entries should be dropped")
+	}
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history")
+	log, err := NewLog(path)
+	if err != nil {
+		t.Fatalf("new log: %v", err)
+	}
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	visits := []Entry{
+		{now, "gemini://example.org/", "Example\tcapsule"},
+		{now.Add(time.Minute), "gemini://other.org/gemlog/", "Gemlog"},
+		{now.Add(2 * time.Minute), "gemini://example.org/about", ""},
+	}
+	for _, v := range visits {
+		if err := log.Add(v.URL, v.Title, v.Time); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	reloaded, err := NewLog(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	found := reloaded.Search("EXAMPLE", 0)
+	want := []Entry{
+		{now.Add(2 * time.Minute), "gemini://example.org/about", ""},
+		{now, "gemini://example.org/", "Example capsule"},
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Fatalf("unexpected search result: %+v", found)
+	}
+
+	if found := reloaded.Search("gemlog", 0); len(found) != 1 || found[0].Title != "Gemlog" {
+		t.Errorf("search by title failed: %+v", found)
+	}
+	if found := reloaded.Search("", 2); len(found) != 2 || found[0].URL != "gemini://example.org/about" {
+		t.Errorf("limit failed: %+v", found)
+	}
+}
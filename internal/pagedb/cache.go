@@ -0,0 +1,161 @@
+package pagedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+// Forever is TTL of pages which never expire, e.g. when browsing crawler database
+const Forever = time.Duration(math.MaxInt64)
+
+// Cache keeps successful responses in DB, pages younger than TTL are served without requests
+type Cache struct {
+	DB  *DB
+	TTL time.Duration
+	// Offline forbids requests, pages missing in DB are appended to Queue for the crawler if it is set
+	Offline bool
+	Queue   string
+}
+
+// DefaultCacheDir returns cache location in user cache dir, pages there can be deleted any time
+func DefaultCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "gemini-tools"), nil
+}
+
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{DB: New(dir), TTL: ttl}
+}
+
+// Get returns cached response for the link with the time it was fetched,
+// fresh reports whether it is younger than TTL; error wraps os.ErrNotExist if nothing is cached
+func (c *Cache) Get(link *url.URL) (resp *gemini.Response, fetched time.Time, fresh bool, err error) {
+	meta, content, err := c.DB.Read(link)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	resp = gemini.NewResponseCode(gemini.CodeSuccess, meta.MIME, content)
+	resp.URL = link
+	if len(meta.Redirects) > 0 {
+		// relative links of redirected pages resolve against the final URL
+		if final, err := url.Parse(meta.Redirects[len(meta.Redirects)-1]); err == nil {
+			resp.URL = final
+			resp.Redirects = []*url.URL{link}
+		}
+	}
+	return resp, meta.LastCrawled, time.Since(meta.LastCrawled) < c.TTL, nil
+}
+
+// Put stores successful buffered text response, other responses are not cached
+func (c *Cache) Put(link *url.URL, resp *gemini.Response) error {
+	if resp.Status != gemini.StatusSuccess || resp.BodyReader != nil {
+		return nil
+	}
+	if mediaType, err := resp.MediaType(); err != nil || !mediaType.IsText() {
+		return nil
+	}
+	return c.DB.Save(link, resp)
+}
+
+// Copy tells that Fetch returned cached page instead of response of the capsule
+type Copy struct {
+	Fetched time.Time
+	// Stale copies are returned when the capsule is unreachable, Reason is the failure of the request
+	Stale  bool
+	Reason error
+}
+
+// Fetch requests link through the cache, fresh cached pages are returned without requests unless reload is set.
+// Offline cache never requests, it queues missing pages instead. Successful text responses are read into memory
+// and stored, other ones keep streaming their body; cached is nil for responses of capsule.
+// Nil Cache only requests the capsule
+func (c *Cache) Fetch(ctx context.Context, client *gemini.Client, link *url.URL, reload bool) (resp *gemini.Response, cached *Copy, err error) {
+	if c == nil {
+		resp, err := client.Stream(ctx, link)
+		return resp, nil, err
+	}
+
+	if !reload || c.Offline {
+		if resp, fetched, fresh, err := c.Get(link); err == nil && fresh {
+			return resp, &Copy{Fetched: fetched}, nil
+		}
+	}
+	if c.Offline {
+		return nil, nil, c.Missing(link)
+	}
+
+	resp, err = client.Stream(ctx, link)
+	if Unreachable(resp, err) {
+		if stale, fetched, _, cacheErr := c.Get(link); cacheErr == nil {
+			if err == nil {
+				err = resp.Err()
+			}
+			return stale, &Copy{Fetched: fetched, Stale: true, Reason: err}, nil
+		}
+	}
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, nil, c.store(link, resp)
+}
+
+// store reads streamed text response into memory and caches it,
+// failing to cache is not reported as the page can be shown anyway
+func (c *Cache) store(link *url.URL, resp *gemini.Response) error {
+	if resp.Status != gemini.StatusSuccess || resp.BodyReader == nil {
+		return nil
+	}
+	if mediaType, err := resp.MediaType(); err != nil || !mediaType.IsText() {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.BodyReader)
+	resp.BodyReader.Close()
+	resp.BodyReader = nil
+	if err != nil {
+		return err
+	}
+	resp.Body = body
+
+	// failing to cache the page only makes it unavailable offline
+	_ = c.Put(link, resp)
+	return nil
+}
+
+// Missing queues link which is not in DB while offline and returns error describing it
+func (c *Cache) Missing(link *url.URL) error {
+	canonical := gemini.CanonicalString(link)
+	if c.Queue == "" {
+		return fmt.Errorf("not found in local DB: %s", canonical)
+	}
+	if err := AppendQueue(c.Queue, canonical); err != nil {
+		return fmt.Errorf("not found in local DB: %s, queueing failed: %w", canonical, err)
+	}
+	return fmt.Errorf("not found in local DB, queued for crawler: %s", canonical)
+}
+
+// Unreachable reports whether request failed because of the network or capsule is temporarily unavailable,
+// so a stale cached copy may be shown instead. Malformed responses, refused redirects, too large bodies,
+// cancelled requests and changed certificates are not such failures, they are shown as is
+func Unreachable(resp *gemini.Response, err error) bool {
+	if err != nil {
+		// dial errors and timeouts, deadline of the request context included
+		var netErr net.Error
+		return errors.As(err, &netErr) && !errors.Is(err, context.Canceled)
+	}
+	return resp != nil && resp.Status == gemini.StatusTemporaryFailure
+}
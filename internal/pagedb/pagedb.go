@@ -0,0 +1,226 @@
+// Package pagedb stores fetched pages on disk in the layout shared by the crawler and clients:
+// <dir>/<host>/pages/<id><ext> for content and <dir>/<host>/pages/meta/<id>.meta.json for meta.
+package pagedb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+)
+
+const PermissionsFull = 0o755
+const PermissionsNonExecutable = 0o644
+
+// StatusSuccess is Meta.Status of pages whose content is stored
+const StatusSuccess = "success"
+
+// ErrInvalidHost is returned for hosts which cannot be used as directory names, e.g. ".."
+var ErrInvalidHost = errors.New("invalid host for page database")
+
+type Meta struct {
+	URL         string    `json:"url"`
+	LastCrawled time.Time `json:"last_crawled"`
+	Status      string    `json:"status"`
+	MIME        string    `json:"mime"`
+	SizeBytes   int       `json:"size_bytes"`
+	Version     int       `json:"version"`
+	// Redirects lists URLs the page was redirected to, the last one is final
+	Redirects []string `json:"redirects,omitempty"`
+	// Lang is the lang parameter of the response media type, if any
+	Lang string `json:"lang,omitempty"`
+}
+
+// DB is a pages directory, e.g. the crawler database
+type DB struct {
+	Dir string
+}
+
+func New(dir string) *DB {
+	return &DB{Dir: dir}
+}
+
+// PageID returns host directory and file id of the page, derived from its canonical URL
+func PageID(u *url.URL) (host, id string) {
+	if normalized, err := gemini.Normalize(u); err == nil {
+		u = normalized
+	}
+	host = u.Host
+	canonicalLink := u.String()
+
+	hashBytes := sha256.Sum256([]byte(canonicalLink))
+	hash := hex.EncodeToString(hashBytes[:])
+
+	slug := slugFromPath(u.Path)
+
+	id = fmt.Sprintf("%s__%s", slug, hash)
+	return host, id
+}
+
+var slugRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func slugFromPath(p string) string {
+	if p == "" || p == "/" {
+		return "root"
+	}
+
+	parts := strings.Split(strings.TrimSuffix(p, "/"), "/")
+	last := parts[len(parts)-1]
+	last = slugRe.ReplaceAllString(last, "-")
+	if len(last) > 80 {
+		last = last[:80]
+	}
+
+	if last == "" || last == "-" {
+		return "page"
+	}
+	return last
+}
+
+// checkHost refuses hosts which would point outside of the database dir
+func checkHost(host string) error {
+	if host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidHost, host)
+	}
+	return nil
+}
+
+func (db *DB) PagesDir(host string) string {
+	return filepath.Join(db.Dir, host, "pages")
+}
+
+func (db *DB) MetaPath(host, id string) string {
+	return filepath.Join(db.PagesDir(host), "meta", id+".meta.json")
+}
+
+// ContentPath returns path of page content, extension depends on its media type
+func (db *DB) ContentPath(host, id, mime string) string {
+	ext := ".bin"
+	mimeLower := strings.ToLower(mime)
+	if strings.HasPrefix(mimeLower, gemini.GeminiMediaType) {
+		ext = ".gmi"
+	} else if strings.HasPrefix(mimeLower, "text/") {
+		ext = ".txt"
+	} else if strings.HasPrefix(mimeLower, "image/jpeg") {
+		ext = ".jpg"
+	} else if strings.HasPrefix(mimeLower, "image/png") {
+		ext = ".png"
+	}
+	return filepath.Join(db.PagesDir(host), id+ext)
+}
+
+// ReadMeta returns stored meta of the page, error wraps os.ErrNotExist if page is unknown
+func (db *DB) ReadMeta(link *url.URL) (Meta, error) {
+	host, id := PageID(link)
+	var meta Meta
+	if err := checkHost(host); err != nil {
+		return meta, err
+	}
+	bytes, err := os.ReadFile(db.MetaPath(host, id))
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(bytes, &meta); err != nil {
+		return meta, fmt.Errorf("invalid meta: %w", err)
+	}
+	return meta, nil
+}
+
+// Read returns meta and content of successfully fetched page
+func (db *DB) Read(link *url.URL) (Meta, []byte, error) {
+	meta, err := db.ReadMeta(link)
+	if err != nil {
+		return meta, nil, err
+	}
+	if meta.Status != StatusSuccess {
+		return meta, nil, fmt.Errorf("page was not fetched successfully: %s: %w", meta.Status, os.ErrNotExist)
+	}
+
+	host, id := PageID(link)
+	content, err := os.ReadFile(db.ContentPath(host, id, meta.MIME))
+	if err != nil {
+		return meta, nil, fmt.Errorf("content missing: %w", err)
+	}
+	return meta, content, nil
+}
+
+// WriteContent stores page content, meta is written separately after it
+func (db *DB) WriteContent(host, id, mime string, content []byte) error {
+	if err := checkHost(host); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(db.PagesDir(host), PermissionsFull); err != nil {
+		return err
+	}
+	return writeFile(db.ContentPath(host, id, mime), content)
+}
+
+func (db *DB) WriteMeta(host, id string, meta Meta) error {
+	if err := checkHost(host); err != nil {
+		return err
+	}
+	metaBytes, _ := json.MarshalIndent(&meta, "", "  ")
+	metaPath := db.MetaPath(host, id)
+	// ensure meta directory exists
+	if err := os.MkdirAll(filepath.Dir(metaPath), PermissionsFull); err != nil {
+		return err
+	}
+	return writeFile(metaPath, metaBytes)
+}
+
+// Save stores successful response body with its meta
+func (db *DB) Save(link *url.URL, resp *gemini.Response) error {
+	host, id := PageID(link)
+	if err := db.WriteContent(host, id, resp.Meta, resp.Body); err != nil {
+		return err
+	}
+
+	meta := NewMeta(gemini.CanonicalString(link), StatusSuccess, len(resp.Body))
+	meta.MIME = resp.Meta
+	if mediaType, err := resp.MediaType(); err == nil {
+		meta.Lang = mediaType.Lang()
+	}
+	meta.Redirects = RedirectChain(resp)
+	return db.WriteMeta(host, id, meta)
+}
+
+func NewMeta(canonical, status string, size int) Meta {
+	return Meta{
+		URL:         canonical,
+		LastCrawled: time.Now().UTC(),
+		Status:      status,
+		SizeBytes:   size,
+		Version:     1,
+	}
+}
+
+// RedirectChain lists canonical URLs the response was redirected through, up to the final one
+func RedirectChain(resp *gemini.Response) []string {
+	if len(resp.Redirects) == 0 {
+		return nil
+	}
+
+	chain := make([]string, 0, len(resp.Redirects))
+	for _, link := range resp.Redirects[1:] {
+		chain = append(chain, gemini.CanonicalString(link))
+	}
+	return append(chain, gemini.CanonicalString(resp.URL))
+}
+
+// writeFile replaces file through temporary one, so readers never see partial content
+func writeFile(path string, content []byte) error {
+	pathTemp := path + ".tmp"
+	if err := os.WriteFile(pathTemp, content, PermissionsNonExecutable); err != nil {
+		return err
+	}
+	return os.Rename(pathTemp, path)
+}
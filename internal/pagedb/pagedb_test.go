@@ -0,0 +1,278 @@
+package pagedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemini/geminitest"
+)
+
+func TestPageID(t *testing.T) {
+	u, _ := url.Parse("gemini://Example.org:1965/notes/post.gmi")
+	host, id := PageID(u)
+	if host != "example.org" {
+		t.Fatalf("host: %s", host)
+	}
+	if !strings.HasPrefix(id, "post.gmi__") {
+		t.Fatalf("id: %s", id)
+	}
+
+	// the same page written differently has the same id
+	same, _ := url.Parse("gemini://example.org/notes/post.gmi")
+	if _, sameID := PageID(same); sameID != id {
+		t.Fatalf("ids differ: %s %s", id, sameID)
+	}
+}
+
+func TestSlugFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "root"},
+		{"/", "root"},
+		{"/gemlog/", "gemlog"},
+		{"/a b/c?d.gmi", "c-d.gmi"},
+		{"/%%%", "page"},
+		{"/" + strings.Repeat("x", 100), strings.Repeat("x", 80)},
+	}
+	for _, test := range tests {
+		if got := slugFromPath(test.path); got != test.want {
+			t.Errorf("slugFromPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestSaveRead(t *testing.T) {
+	db := New(t.TempDir())
+	link, _ := url.Parse("gemini://example.org/page")
+	final, _ := url.Parse("gemini://example.org/page/")
+
+	if _, _, err := db.Read(link); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+
+	resp := gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini; lang=en", []byte("# Page\n"))
+	resp.URL = final
+	resp.Redirects = []*url.URL{link}
+	if err := db.Save(link, resp); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	meta, content, err := db.Read(link)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(content) != "# Page\n" {
+		t.Fatalf("content: %q", content)
+	}
+	if meta.URL != "gemini://example.org/page" || meta.Status != StatusSuccess || meta.Lang != "en" || meta.SizeBytes != 7 {
+		t.Fatalf("meta: %+v", meta)
+	}
+	if len(meta.Redirects) != 1 || meta.Redirects[0] != "gemini://example.org/page/" {
+		t.Fatalf("redirects: %v", meta.Redirects)
+	}
+
+	host, id := PageID(link)
+	if _, err := os.Stat(filepath.Join(db.Dir, "example.org", "pages", id+".gmi")); err != nil {
+		t.Fatalf("content file: %v", err)
+	}
+	if _, err := os.Stat(db.MetaPath(host, id)); err != nil {
+		t.Fatalf("meta file: %v", err)
+	}
+
+	// failures keep only meta, there is nothing to read
+	failed, _ := url.Parse("gemini://example.org/missing")
+	host, id = PageID(failed)
+	if err := db.WriteMeta(host, id, NewMeta(failed.String(), "status-51", 0)); err != nil {
+		t.Fatalf("write meta: %v", err)
+	}
+	if _, _, err := db.Read(failed); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist for failed page, got %v", err)
+	}
+}
+
+func TestInvalidHosts(t *testing.T) {
+	root := t.TempDir()
+	db := New(filepath.Join(root, "db"))
+	resp := gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini", []byte("page"))
+
+	for _, host := range []string{"..", ".", "", "a/b", `a\b`} {
+		link := &url.URL{Scheme: "gemini", Host: host, Path: "/x"}
+		if err := db.Save(link, resp); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("save %q: expected invalid host, got %v", host, err)
+		}
+		if _, err := db.ReadMeta(link); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("read %q: expected invalid host, got %v", host, err)
+		}
+		if err := db.WriteMeta(host, "id", NewMeta(link.String(), "status-51", 0)); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("write meta %q: expected invalid host, got %v", host, err)
+		}
+	}
+
+	// nothing is written next to the database dir
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 0 {
+		t.Fatalf("files written outside of db: %v", entries)
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	link, _ := url.Parse("gemini://example.org/")
+
+	if _, _, _, err := cache.Get(link); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+
+	image := gemini.NewResponseCode(gemini.CodeSuccess, "image/png", []byte{1, 2})
+	if err := cache.Put(link, image); err != nil {
+		t.Fatalf("put image: %v", err)
+	}
+	if _, _, _, err := cache.Get(link); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("non-text response cached: %v", err)
+	}
+
+	if err := cache.Put(link, gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini", []byte("hi"))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	resp, fetched, fresh, err := cache.Get(link)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !fresh || time.Since(fetched) > time.Minute {
+		t.Fatalf("expected fresh page, fetched %v", fetched)
+	}
+	if resp.Code != gemini.CodeSuccess || resp.Meta != "text/gemini" || string(resp.Body) != "hi" || resp.URL != link {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	cache.TTL = 0
+	if _, _, fresh, err := cache.Get(link); err != nil || fresh {
+		t.Fatalf("expected stale page, fresh %v err %v", fresh, err)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *gemini.Response
+		err  error
+		want bool
+	}{
+		{"network error", nil, fmt.Errorf("connection failed: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), true},
+		{"timeout", nil, fmt.Errorf("request cancelled: %w", context.DeadlineExceeded), true},
+		{"cancelled", nil, context.Canceled, false},
+		{"cancelled dial", nil, &net.OpError{Op: "dial", Net: "tcp", Err: context.Canceled}, false},
+		{"certificate changed", nil, &gemini.CertMismatchError{Addr: "example.org:1965"}, false},
+		{"malformed header", nil, &gemini.HeaderError{Header: "2x", Err: errors.New("invalid status")}, false},
+		{"redirect loop", nil, fmt.Errorf("%w: gemini://example.org/", gemini.ErrRedirectLoop), false},
+		{"too large", nil, gemini.ErrBodyTooLarge, false},
+		{"input cancelled", nil, errors.New("input cancelled"), false},
+		{"server unavailable", gemini.NewResponseCode(41, "down", nil), nil, true},
+		{"not found", gemini.NewResponseCode(51, "not found", nil), nil, false},
+		{"success", gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini", nil), nil, false},
+	}
+	for _, test := range tests {
+		if got := Unreachable(test.resp, test.err); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCacheFetch(t *testing.T) {
+	client := gemini.NewClient()
+	client.DialTLSContext = geminitest.Dialer(map[string]string{
+		"gemini://example.org:1965/page":  "20 text/gemini\r\n# Page\n",
+		"gemini://example.org:1965/image": "20 image/png\r\npng",
+		"gemini://example.org:1965/down":  "41 maintenance\r\n",
+	})
+	cache := NewCache(t.TempDir(), time.Hour)
+	ctx := context.Background()
+
+	page, _ := url.Parse("gemini://example.org:1965/page")
+	resp, cached, err := cache.Fetch(ctx, client, page, false)
+	if err != nil || cached != nil || resp.BodyReader != nil || string(resp.Body) != "# Page\n" {
+		t.Fatalf("unexpected response of capsule: %+v %+v %v", resp, cached, err)
+	}
+	if resp, cached, err = cache.Fetch(ctx, client, page, false); err != nil || cached == nil || cached.Stale || string(resp.Body) != "# Page\n" {
+		t.Fatalf("fresh page should be cached: %+v %v", cached, err)
+	}
+
+	image, _ := url.Parse("gemini://example.org:1965/image")
+	if resp, _, err = cache.Fetch(ctx, client, image, false); err != nil || resp.BodyReader == nil {
+		t.Fatalf("non-text body should be streamed: %+v %v", resp, err)
+	}
+	resp.BodyReader.Close()
+	if _, _, _, err := cache.Get(image); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("non-text response cached: %v", err)
+	}
+
+	down, _ := url.Parse("gemini://example.org:1965/down")
+	if err := cache.Put(down, gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini", []byte("old"))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	cache.TTL = 0
+	resp, cached, err = cache.Fetch(ctx, client, down, false)
+	var statusErr *gemini.StatusError
+	if err != nil || cached == nil || !cached.Stale || !errors.As(cached.Reason, &statusErr) || string(resp.Body) != "old" {
+		t.Fatalf("stale copy expected: %+v %v", cached, err)
+	}
+
+	var disabled *Cache
+	if resp, cached, err = disabled.Fetch(ctx, client, down, false); err != nil || cached != nil || resp.Code != 41 {
+		t.Fatalf("disabled cache should only request: %+v %+v %v", resp, cached, err)
+	}
+}
+
+func TestCacheMissing(t *testing.T) {
+	dir := t.TempDir()
+	cache := &Cache{DB: New(filepath.Join(dir, "db")), TTL: Forever, Offline: true, Queue: filepath.Join(dir, "queue.txt")}
+	link, _ := url.Parse("gemini://Example.org:1965/missing")
+
+	if err := cache.Missing(link); err == nil || !strings.Contains(err.Error(), "queued") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.Missing(link); err == nil {
+		t.Fatalf("expected error for missing page")
+	}
+	queue, _ := os.ReadFile(cache.Queue)
+	if string(queue) != "gemini://example.org/missing\ngemini://example.org/missing\n" {
+		t.Fatalf("unexpected queue: %q", queue)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	db := New(t.TempDir())
+	for _, raw := range []string{"gemini://b.org/", "gemini://a.org/", "gemini://c.org/1", "gemini://c.org/2"} {
+		link, _ := url.Parse(raw)
+		if err := db.Save(link, gemini.NewResponseCode(gemini.CodeSuccess, "text/gemini", []byte("page"))); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	// hosts with failed pages only are not listed
+	failed, _ := url.Parse("gemini://d.org/")
+	host, id := PageID(failed)
+	if err := db.WriteMeta(host, id, NewMeta(failed.String(), "status-51", 0)); err != nil {
+		t.Fatalf("write meta: %v", err)
+	}
+
+	hosts, err := db.Hosts()
+	if err != nil {
+		t.Fatalf("hosts: %v", err)
+	}
+	want := []HostPages{{"c.org", 2}, {"a.org", 1}, {"b.org", 1}}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+}
@@ -0,0 +1,68 @@
+package pagedb
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// AppendQueue adds links to crawler queue file, one per line
+func AppendQueue(path string, links ...string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, PermissionsNonExecutable)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, link := range links {
+		if _, err := file.WriteString(link + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HostPages is number of pages stored for a host
+type HostPages struct {
+	Host  string
+	Pages int
+}
+
+// Hosts lists hosts having stored pages, most pages first
+func (db *DB) Hosts() ([]HostPages, error) {
+	entries, err := os.ReadDir(db.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]HostPages, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pages, err := os.ReadDir(db.PagesDir(entry.Name()))
+		if err != nil {
+			// skip hosts without pages dir
+			continue
+		}
+
+		count := 0
+		for _, page := range pages {
+			// meta is stored under pages/meta/, so page files live directly under pages/
+			if !page.IsDir() && !strings.HasSuffix(page.Name(), ".tmp") {
+				count++
+			}
+		}
+		if count > 0 {
+			hosts = append(hosts, HostPages{Host: entry.Name(), Pages: count})
+		}
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Pages == hosts[j].Pages {
+			return hosts[i].Host < hosts[j].Host
+		}
+		return hosts[i].Pages > hosts[j].Pages
+	})
+	return hosts, nil
+}
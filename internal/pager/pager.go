@@ -0,0 +1,191 @@
+// Package pager shows rendered pages a screen at a time, with search and link selection
+package pager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/romanthekat/gemini-tools/internal/render"
+)
+
+const (
+	keyBackspace = 127
+	keyCtrlH     = 8
+	keyEscape    = 27
+)
+
+// Pager reads single key commands from In, which should be in cbreak mode for keys
+// to work without Enter. It shares In with the client, so no input is lost between them
+type Pager struct {
+	In  *bufio.Reader
+	Out io.Writer
+	// Height is screen height in rows, one of them is used for the prompt
+	Height int
+
+	pattern *regexp.Regexp
+}
+
+func New(in *bufio.Reader, out io.Writer, height int) *Pager {
+	return &Pager{In: in, Out: out, Height: height}
+}
+
+// Run shows lines page by page until the user quits or scrolls past the end.
+// Returns link number the user typed to open, zero otherwise.
+// Lines fitting on one screen are printed right away
+func (p *Pager) Run(lines []string) (int, error) {
+	pageSize := p.Height - 1
+	if pageSize < 1 || len(lines) <= pageSize {
+		return 0, p.print(lines)
+	}
+
+	lastTop := len(lines) - pageSize
+	top := 0
+	if err := p.print(lines[:pageSize]); err != nil {
+		return 0, err
+	}
+
+	for {
+		bottom := min(top+pageSize, len(lines))
+		fmt.Fprintf(p.Out, "\033[7m-- %d-%d/%d -- space/b page, /search, n next, NUM open link, q quit\033[0m",
+			top+1, bottom, len(lines))
+
+		key, err := p.In.ReadByte()
+		if errors.Is(err, io.EOF) {
+			fmt.Fprint(p.Out, "\r\033[K")
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		next := top
+		message := ""
+		switch {
+		case key == 'q':
+			fmt.Fprint(p.Out, "\r\033[K")
+			return 0, nil
+
+		case key == ' ' || key == 'f':
+			if top >= lastTop {
+				fmt.Fprint(p.Out, "\r\033[K")
+				return 0, nil
+			}
+			next = min(top+pageSize, lastTop)
+
+		case key == '\n' || key == '\r' || key == 'j':
+			if top >= lastTop {
+				fmt.Fprint(p.Out, "\r\033[K")
+				return 0, nil
+			}
+			next = top + 1
+
+		case key == 'b' || key == 'k':
+			step := pageSize
+			if key == 'k' {
+				step = 1
+			}
+			next = max(top-step, 0)
+
+		case key == '/':
+			fmt.Fprint(p.Out, "\r\033[K/")
+			text, ok, err := p.readLine("")
+			if err != nil {
+				return 0, err
+			}
+			if !ok || text == "" {
+				break
+			}
+			pattern, err := regexp.Compile("(?i)" + text)
+			if err != nil {
+				pattern = regexp.MustCompile("(?i)" + regexp.QuoteMeta(text))
+			}
+			p.pattern = pattern
+			next, message = p.search(lines, top+1)
+
+		case key == 'n':
+			next, message = p.search(lines, top+1)
+
+		case key >= '0' && key <= '9':
+			fmt.Fprintf(p.Out, "\r\033[K:%c", key)
+			text, ok, err := p.readLine(string(key))
+			if err != nil {
+				return 0, err
+			}
+			if !ok || text == "" {
+				break
+			}
+			number, err := strconv.Atoi(text)
+			if err != nil || number <= 0 {
+				message = "not a link number"
+				break
+			}
+			fmt.Fprint(p.Out, "\r\033[K")
+			return number, nil
+		}
+
+		fmt.Fprint(p.Out, "\r\033[K")
+		if message != "" {
+			fmt.Fprintf(p.Out, "\033[31m%s\033[0m\n", message)
+		}
+		if next != top || message != "" {
+			top = next
+			if err := p.print(lines[top:min(top+pageSize, len(lines))]); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// search returns first line from start matching current pattern
+func (p *Pager) search(lines []string, start int) (int, string) {
+	if p.pattern == nil {
+		return start - 1, "no previous search"
+	}
+	for i := max(start, 0); i < len(lines); i++ {
+		if p.pattern.MatchString(render.StripANSI(lines[i])) {
+			return i, ""
+		}
+	}
+	return start - 1, "pattern not found"
+}
+
+// readLine reads text after already typed initial one until Enter echoing it, Escape cancels the input
+func (p *Pager) readLine(initial string) (string, bool, error) {
+	text := []byte(initial)
+	for {
+		key, err := p.In.ReadByte()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", false, err
+		}
+
+		switch {
+		case err != nil || key == '\n' || key == '\r':
+			return string(text), true, nil
+		case key == keyEscape:
+			return "", false, nil
+		case key == keyBackspace || key == keyCtrlH:
+			if len(text) > 0 {
+				_, size := utf8.DecodeLastRune(text)
+				text = text[:len(text)-size]
+				fmt.Fprint(p.Out, "\b \b")
+			}
+		default:
+			text = append(text, key)
+			_, _ = p.Out.Write([]byte{key})
+		}
+	}
+}
+
+func (p *Pager) print(lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(p.Out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package pager
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return lines
+}
+
+// shownTops returns first line of every page printed, in order
+func shownTops(out string) []string {
+	var tops []string
+	for _, chunk := range strings.Split(out, "\033[K") {
+		chunk = strings.TrimPrefix(chunk, "\n")
+		if strings.HasPrefix(chunk, "line ") {
+			tops = append(tops, strings.SplitN(chunk, "\n", 2)[0])
+		}
+	}
+	return tops
+}
+
+func runPager(t *testing.T, input string, lines []string) (int, string) {
+	t.Helper()
+	var out bytes.Buffer
+	p := New(bufio.NewReader(strings.NewReader(input)), &out, 5)
+	link, err := p.Run(lines)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return link, out.String()
+}
+
+func TestPagerShortPage(t *testing.T) {
+	link, out := runPager(t, "", testLines(4))
+	if link != 0 || out != "line 1\nline 2\nline 3\nline 4\n" {
+		t.Fatalf("short page should be printed as is: %d, %q", link, out)
+	}
+}
+
+func TestPagerPaging(t *testing.T) {
+	// 10 lines in pages of 4: space, space clamps to last page, b goes back, q quits
+	link, out := runPager(t, "  bq", testLines(10))
+	if link != 0 {
+		t.Fatalf("unexpected link: %d", link)
+	}
+	tops := shownTops(out)
+	want := []string{"line 1", "line 5", "line 7", "line 3"}
+	if strings.Join(tops, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected pages: %v, want %v", tops, want)
+	}
+
+	// space on the last page leaves the pager
+	if link, _ := runPager(t, "   ", testLines(10)); link != 0 {
+		t.Fatalf("unexpected link: %d", link)
+	}
+}
+
+func TestPagerSearch(t *testing.T) {
+	lines := testLines(20)
+	lines[12] = "line 13 \033[34mSome Match\033[0m"
+	lines[16] = "line 17 another match"
+
+	_, out := runPager(t, "/match\nnnq", lines)
+	tops := shownTops(out)
+	if len(tops) != 3 || tops[1] != "line 13 \033[34mSome Match\033[0m" || tops[2] != "line 17 another match" {
+		t.Fatalf("unexpected pages: %v", tops)
+	}
+	if !strings.Contains(out, "pattern not found") {
+		t.Fatalf("expected not found message: %q", out)
+	}
+}
+
+func TestPagerLinkNumber(t *testing.T) {
+	link, _ := runPager(t, " 1\x7f12\n", testLines(10))
+	if link != 12 {
+		t.Fatalf("expected link 12, got %d", link)
+	}
+
+	// escape cancels number input
+	link, _ = runPager(t, "3\x1bq", testLines(10))
+	if link != 0 {
+		t.Fatalf("expected no link, got %d", link)
+	}
+}
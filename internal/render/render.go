@@ -0,0 +1,313 @@
+// Package render formats gemtext documents for terminal output
+package render
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/term"
+)
+
+// DefaultWidth is used when terminal width cannot be detected
+const DefaultWidth = 80
+
+const colorReset = "\033[0m"
+
+var ansiRe = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// Theme holds color sequences of line types, empty color leaves text as is
+type Theme struct {
+	Link  string
+	Quote string
+	// Headings are colors of heading levels 1-3
+	Headings [3]string
+}
+
+// DefaultTheme shows links blue, quotes dim, and headings red, green and orange
+var DefaultTheme = Theme{
+	Link:     "\033[34m",
+	Quote:    "\033[2m",
+	Headings: [3]string{"\033[31m", "\033[32m", "\033[33m"},
+}
+
+// colors are names accepted by ParseColor
+var colors = map[string]string{
+	"none":    "",
+	"black":   "\033[30m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"white":   "\033[37m",
+	"bold":    "\033[1m",
+	"dim":     "\033[2m",
+	"italic":  "\033[3m",
+}
+
+// ParseColor returns color sequence for a name like "blue" or "none", or for an SGR code like "38;5;208"
+func ParseColor(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if color, ok := colors[name]; ok {
+		return color, nil
+	}
+	if name != "" && strings.Trim(name, "0123456789;") == "" {
+		return "\033[" + name + "m", nil
+	}
+	return "", fmt.Errorf("unknown color %q", name)
+}
+
+// Renderer wraps gemtext to a fixed width, preformatted blocks are never wrapped
+type Renderer struct {
+	// Width in columns, zero or negative disables wrapping
+	Width int
+	Theme Theme
+}
+
+func New(width int) *Renderer {
+	return &Renderer{Width: width, Theme: DefaultTheme}
+}
+
+// TerminalWidth returns width of terminal on fd, DefaultWidth if it is not a terminal
+func TerminalWidth(fd int) int {
+	width, err := term.Width(fd)
+	if err != nil || width <= 0 {
+		return DefaultWidth
+	}
+	return width
+}
+
+// Render writes document to w, links are numbered from 1 in document order
+// and shown by label, or by URL resolved against base if they have none
+func (r *Renderer) Render(w io.Writer, doc gemtext.Document, base *url.URL) error {
+	for _, line := range r.Lines(doc, base) {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lines renders document into terminal lines like Render
+func (r *Renderer) Lines(doc gemtext.Document, base *url.URL) []string {
+	lines, _ := r.Layout(doc, base)
+	return lines
+}
+
+// Layout renders document like Lines and also returns index of the first line of every link
+func (r *Renderer) Layout(doc gemtext.Document, base *url.URL) (lines []string, linkLines []int) {
+	var out []string
+	linkNumber := 0
+
+	for _, line := range doc {
+		switch line := line.(type) {
+		case gemtext.Text:
+			out = append(out, r.wrap(string(line), "", "", "")...)
+
+		case gemtext.Heading:
+			prefix := strings.Repeat("#", line.Level) + " "
+			out = append(out, r.wrap(line.Text, prefix, "", r.Theme.Headings[line.Level-1])...)
+
+		case gemtext.Link:
+			linkNumber++
+			name := line.Label
+			if name == "" {
+				name = line.URL
+				if resolved, err := line.Resolve(base); err == nil {
+					name = resolved.String()
+				}
+			}
+			linkLines = append(linkLines, len(out))
+			out = append(out, r.wrap(name, fmt.Sprintf("[%d] ", linkNumber), "", r.Theme.Link)...)
+
+		case gemtext.ListItem:
+			out = append(out, r.wrap(string(line), "• ", "", "")...)
+
+		case gemtext.Quote:
+			out = append(out, r.wrap(string(line), "> ", "> ", r.Theme.Quote)...)
+
+		case gemtext.Preformatted:
+			out = append(out, line.Lines...)
+		}
+	}
+	return out, linkLines
+}
+
+// wrap splits text into lines of at most Width columns. First line starts with prefix,
+// following ones with indent, or spaces of prefix width if indent is empty
+func (r *Renderer) wrap(text, prefix, indent, color string) []string {
+	if indent == "" {
+		indent = strings.Repeat(" ", StringWidth(prefix))
+	}
+
+	available := r.Width - StringWidth(prefix)
+	if r.Width <= 0 || available < 1 {
+		return []string{prefix + colored(text, color)}
+	}
+
+	var lines []string
+	for _, line := range Wrap(text, available) {
+		lines = append(lines, colored(line, color))
+	}
+	for i := range lines {
+		if i == 0 {
+			lines[i] = prefix + lines[i]
+		} else {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return lines
+}
+
+// StripANSI removes color sequences added by the renderer
+func StripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// Truncate cuts s to at most width columns, keeping color sequences intact
+func Truncate(s string, width int) string {
+	if StringWidth(StripANSI(s)) <= width {
+		return s
+	}
+
+	var b strings.Builder
+	taken := 0
+	for i := 0; i < len(s); {
+		if loc := ansiRe.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			b.WriteString(s[i : i+loc[1]])
+			i += loc[1]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if taken+RuneWidth(r) > width {
+			break
+		}
+		taken += RuneWidth(r)
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	if strings.Contains(s, "\033[") {
+		b.WriteString(colorReset)
+	}
+	return b.String()
+}
+
+func colored(text, color string) string {
+	if color == "" || text == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// Wrap splits text on whitespace into lines of at most width columns,
+// words longer than width are split. Empty text gives single empty line
+func Wrap(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 || width <= 0 {
+		return []string{strings.TrimSpace(text)}
+	}
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	for _, word := range words {
+		wordWidth := StringWidth(word)
+
+		if lineWidth > 0 && lineWidth+1+wordWidth > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+
+		for wordWidth > width {
+			head, tail := splitAtWidth(word, width)
+			line.WriteString(head)
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+			word = tail
+			wordWidth = StringWidth(word)
+		}
+
+		if lineWidth > 0 {
+			line.WriteByte(' ')
+			lineWidth++
+		}
+		line.WriteString(word)
+		lineWidth += wordWidth
+	}
+	if lineWidth > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// splitAtWidth splits s so that head takes at most width columns, but at least one rune
+func splitAtWidth(s string, width int) (head, tail string) {
+	taken := 0
+	for i, r := range s {
+		w := RuneWidth(r)
+		if taken+w > width && i > 0 {
+			return s[:i], s[i:]
+		}
+		taken += w
+	}
+	return s, ""
+}
+
+// StringWidth returns number of terminal columns s takes
+func StringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += RuneWidth(r)
+	}
+	return width
+}
+
+// RuneWidth returns number of terminal columns r takes: zero for combining marks
+// and control characters, two for East Asian wide characters and emoji
+func RuneWidth(r rune) int {
+	switch {
+	case r == utf8.RuneError:
+		return 1
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.IsControl(r) || r == '\u200d':
+		return 0
+	case isWide(r):
+		return 2
+	}
+	return 1
+}
+
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK radicals and punctuation
+	{0x3041, 0x33FF},   // Kana and CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // pictographs and emoticons
+	{0x1F900, 0x1F9FF}, // supplemental pictographs
+	{0x20000, 0x3FFFD}, // CJK extensions B and later
+}
+
+func isWide(r rune) bool {
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return true
+		}
+	}
+	return false
+}
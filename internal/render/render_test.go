@@ -0,0 +1,134 @@
+package render
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+)
+
+func stripANSI(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = StripANSI(line)
+	}
+	return out
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"one two three four", 9, []string{"one two", "three", "four"}},
+		{"  spaced   out  ", 20, []string{"spaced out"}},
+		{"abcdefghij xy", 4, []string{"abcd", "efgh", "ij", "xy"}},
+		{"日本語のテキスト", 6, []string{"日本語", "のテキ", "スト"}},
+		{"no wrapping at all", 0, []string{"no wrapping at all"}},
+	}
+
+	for _, tt := range tests {
+		if got := Wrap(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRendererLines(t *testing.T) {
+	doc := gemtext.ParseString("# A long heading text\n" +
+		"Some paragraph text that wraps\n" +
+		"=> /docs\n" +
+		"=> gemini://example.org/x Labelled link goes here\n" +
+		"* list item that wraps\n" +
+		"> quoted words that wrap\n" +
+		"```\n" +
+		"preformatted line that is never wrapped\n" +
+		"```\n")
+	base, _ := url.Parse("gemini://example.org/dir/")
+
+	lines, linkLines := New(16).Layout(doc, base)
+	if !reflect.DeepEqual(linkLines, []int{4, 7}) {
+		t.Errorf("unexpected link lines: %v", linkLines)
+	}
+
+	got := stripANSI(lines)
+	want := []string{
+		"# A long heading",
+		"  text",
+		"Some paragraph",
+		"text that wraps",
+		"[1] gemini://exa",
+		"    mple.org/doc",
+		"    s",
+		"[2] Labelled",
+		"    link goes",
+		"    here",
+		"• list item that",
+		"  wraps",
+		"> quoted words",
+		"> that wrap",
+		"preformatted line that is never wrapped",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, line := range got[:len(got)-1] {
+		if StringWidth(line) > 16 {
+			t.Errorf("line wider than 16 columns: %q", line)
+		}
+	}
+}
+
+func TestRenderNoWrap(t *testing.T) {
+	var out bytes.Buffer
+	doc := gemtext.ParseString("## Heading\n=> /a A\n")
+	if err := New(0).Render(&out, doc, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := StripANSI(out.String()); got != "## Heading\n[1] A\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"truncated text", 9, "truncated"},
+		{"\033[34mblue text\033[0m", 4, "\033[34mblue\033[0m"},
+		{"日本語", 5, "日本"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"Blue", "\033[34m", false},
+		{"none", "", false},
+		{"1;31", "\033[1;31m", false},
+		{"sparkly", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseColor(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package term holds minimal terminal control used by the interactive clients
+package term
@@ -0,0 +1,109 @@
+//go:build linux
+
+package term
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+func getTermios(fd int) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return termios, nil
+}
+
+func setTermios(fd int, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// DisableEcho stops terminal on fd from echoing typed characters,
+// returned restore func brings previous settings back
+func DisableEcho(fd int) (restore func(), err error) {
+	termios, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *termios
+	termios.Lflag &^= syscall.ECHO
+	termios.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := setTermios(fd, termios); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = setTermios(fd, &previous) }, nil
+}
+
+// Cbreak makes terminal on fd pass every key press without waiting for Enter and without echo,
+// signals like Ctrl-C still work. Returned restore func brings previous settings back
+func Cbreak(fd int) (restore func(), err error) {
+	termios, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *termios
+	termios.Lflag &^= syscall.ECHO | syscall.ICANON
+	termios.Lflag |= syscall.ISIG
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, termios); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = setTermios(fd, &previous) }, nil
+}
+
+// Raw puts terminal on fd into raw mode: keys including Ctrl-C are passed as is, without echo.
+// Output processing is kept, so "\n" still starts a new line. Returned restore func brings previous settings back
+func Raw(fd int) (restore func(), err error) {
+	termios, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *termios
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, termios); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = setTermios(fd, &previous) }, nil
+}
+
+// NotifyResize relays terminal window size changes to c
+func NotifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+// Size returns number of columns and rows of terminal on fd
+func Size(fd int) (width, height int, err error) {
+	var size struct{ rows, cols, xpixel, ypixel uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	return int(size.cols), int(size.rows), nil
+}
+
+// Width returns number of columns of terminal on fd
+func Width(fd int) (int, error) {
+	width, _, err := Size(fd)
+	return width, err
+}
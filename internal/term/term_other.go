@@ -0,0 +1,38 @@
+//go:build !linux
+
+package term
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("terminal control is not supported on this platform")
+
+// DisableEcho is only supported on linux
+func DisableEcho(fd int) (restore func(), err error) {
+	return nil, errUnsupported
+}
+
+// Cbreak is only supported on linux
+func Cbreak(fd int) (restore func(), err error) {
+	return nil, errUnsupported
+}
+
+// Raw is only supported on linux
+func Raw(fd int) (restore func(), err error) {
+	return nil, errUnsupported
+}
+
+// NotifyResize does nothing on platforms without terminal control
+func NotifyResize(c chan<- os.Signal) {}
+
+// Size is only supported on linux
+func Size(fd int) (width, height int, err error) {
+	return 0, 0, errUnsupported
+}
+
+// Width is only supported on linux
+func Width(fd int) (int, error) {
+	return 0, errUnsupported
+}
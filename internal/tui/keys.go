@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+type KeyCode int
+
+const (
+	KeyUnknown KeyCode = iota
+	KeyRune
+	KeyEnter
+	KeyTab
+	KeyBacktab
+	KeyBackspace
+	KeyDelete
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyCtrlC
+	KeyCtrlL
+	KeyCtrlU
+)
+
+// Key is a single key press, Rune is set for KeyRune only
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// ReadKey reads one key press from terminal in raw mode. Escape sequences come in one read,
+// so lone Escape is told apart by nothing else being buffered after it
+func ReadKey(reader *bufio.Reader) (Key, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch b {
+	case '\r', '\n':
+		return Key{Code: KeyEnter}, nil
+	case '\t':
+		return Key{Code: KeyTab}, nil
+	case 127, 8:
+		return Key{Code: KeyBackspace}, nil
+	case 3:
+		return Key{Code: KeyCtrlC}, nil
+	case 12:
+		return Key{Code: KeyCtrlL}, nil
+	case 21:
+		return Key{Code: KeyCtrlU}, nil
+	case 27:
+		if reader.Buffered() == 0 {
+			return Key{Code: KeyEscape}, nil
+		}
+		return readEscape(reader)
+	}
+
+	if b < 32 {
+		return Key{Code: KeyUnknown}, nil
+	}
+	if b < utf8.RuneSelf {
+		return Key{Code: KeyRune, Rune: rune(b)}, nil
+	}
+
+	_ = reader.UnreadByte()
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Code: KeyRune, Rune: r}, nil
+}
+
+// readEscape decodes CSI and SS3 sequences after Escape
+func readEscape(reader *bufio.Reader) (Key, error) {
+	introducer, err := reader.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		return Key{Code: KeyUnknown}, nil
+	}
+
+	// parameters are digits and semicolons, final byte names the key
+	var params []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		if (b >= '0' && b <= '9') || b == ';' {
+			params = append(params, b)
+			continue
+		}
+
+		switch b {
+		case 'A':
+			return Key{Code: KeyUp}, nil
+		case 'B':
+			return Key{Code: KeyDown}, nil
+		case 'C':
+			return Key{Code: KeyRight}, nil
+		case 'D':
+			return Key{Code: KeyLeft}, nil
+		case 'H':
+			return Key{Code: KeyHome}, nil
+		case 'F':
+			return Key{Code: KeyEnd}, nil
+		case 'Z':
+			return Key{Code: KeyBacktab}, nil
+		case '~':
+			switch string(params) {
+			case "1", "7":
+				return Key{Code: KeyHome}, nil
+			case "3":
+				return Key{Code: KeyDelete}, nil
+			case "4", "8":
+				return Key{Code: KeyEnd}, nil
+			case "5":
+				return Key{Code: KeyPageUp}, nil
+			case "6":
+				return Key{Code: KeyPageDown}, nil
+			}
+		}
+		return Key{Code: KeyUnknown}, nil
+	}
+}
@@ -0,0 +1,38 @@
+package tui
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Key
+	}{
+		{"q", Key{Code: KeyRune, Rune: 'q'}},
+		{"ж", Key{Code: KeyRune, Rune: 'ж'}},
+		{"\r", Key{Code: KeyEnter}},
+		{"\t", Key{Code: KeyTab}},
+		{"\x7f", Key{Code: KeyBackspace}},
+		{"\x03", Key{Code: KeyCtrlC}},
+		{"\x1b", Key{Code: KeyEscape}},
+		{"\x1b[A", Key{Code: KeyUp}},
+		{"\x1bOB", Key{Code: KeyDown}},
+		{"\x1b[Z", Key{Code: KeyBacktab}},
+		{"\x1b[5~", Key{Code: KeyPageUp}},
+		{"\x1b[6~", Key{Code: KeyPageDown}},
+		{"\x1b[1;5C", Key{Code: KeyRight}},
+		{"\x1b[4~", Key{Code: KeyEnd}},
+	}
+	for _, tt := range tests {
+		got, err := ReadKey(bufio.NewReader(strings.NewReader(tt.input)))
+		if err != nil {
+			t.Fatalf("ReadKey(%q): %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("ReadKey(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
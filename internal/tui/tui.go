@@ -0,0 +1,1043 @@
+// Package tui is a full-screen terminal browser built on the gemini client and gemtext renderer
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/bookmarks"
+	"github.com/romanthekat/gemini-tools/internal/download"
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/gemtext"
+	"github.com/romanthekat/gemini-tools/internal/history"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+	"github.com/romanthekat/gemini-tools/internal/render"
+	"github.com/romanthekat/gemini-tools/internal/term"
+)
+
+// HomeURL is opened with g unless App.Home is set, like in the line mode client
+const HomeURL = "gemini://geminiprotocol.net:1965/"
+
+const helpPage = `# Keys
+* q: quit
+* h: this help
+* g: open home page
+* B: bookmarks
+* a: bookmark current page
+* d: delete selected bookmark on bookmarks page
+* o: edit address, Enter opens it
+* b: go back
+* f: go forward
+* H, number and Enter: go to history entry of the tab
+* l: links from current page and history of the tab
+* i: status, connection and certificate of current page
+* r: reload
+* t: open selected link in a new tab, or help if none is selected
+* w: close tab
+* [ and ]: previous or next tab
+* Tab, Shift-Tab: select next or previous link, Enter opens it
+* number and Enter: open link by number
+* j/k, arrows: scroll by line
+* space, PgUp/PgDn: scroll by page
+* Home/End: top or bottom of the page
+* Esc, Ctrl-C: cancel request in progress
+`
+
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeAddress
+	modeInput
+	modeLinkNumber
+	modeHistoryNumber
+	modeTrust
+	modeSave
+)
+
+// page is a loaded document laid out for the current width
+type page struct {
+	// url is nil for internal pages like help
+	url   *url.URL
+	title string
+	// status is response code and meta
+	status  string
+	size    int
+	elapsed time.Duration
+	// cached tells that page came from cache instead of capsule, empty if it did not
+	cached string
+	// resp is the response page was shown from, nil for internal pages
+	resp *gemini.Response
+
+	// doc is nil for plain text, which is shown as is
+	doc  gemtext.Document
+	text []string
+
+	lines     []string
+	linkLines []int
+	// links are resolved against page url, nil for malformed ones
+	links []*url.URL
+}
+
+// tab is a browsing context with its own history and position in the page
+type tab struct {
+	page    *page
+	history *history.Stack
+	// pages are loaded pages of history entries, so travelling in history does not request them again
+	pages  map[string]visit
+	scroll int
+	// selected is index of highlighted link, -1 if none
+	selected int
+}
+
+func emptyTab() *tab {
+	return &tab{history: history.NewStack(), pages: map[string]visit{}, selected: -1}
+}
+
+// visit is a page left for another one, restored with its scroll position
+type visit struct {
+	page   *page
+	scroll int
+}
+
+// historyKey is the history entry of p, internal pages have about: keys as they have no url
+func historyKey(p *page) string {
+	if p.url == nil {
+		return "about:" + p.title
+	}
+	return p.url.String()
+}
+
+type loadResult struct {
+	id      int
+	link    *url.URL
+	resp    *gemini.Response
+	err     error
+	elapsed time.Duration
+	reload  bool
+	cached  string
+}
+
+// App draws the whole terminal and handles keys, terminal must be in raw mode
+type App struct {
+	Client   *gemini.Client
+	Renderer *render.Renderer
+	In       *bufio.Reader
+	Out      io.Writer
+	Width    int
+	Height   int
+	// Size reports terminal size after resize signals, nil disables resize handling
+	Size func() (width, height int, err error)
+	// History records every loaded page, nil disables it
+	History *history.Log
+	// Bookmarks are shown with B, nil disables them
+	Bookmarks *bookmarks.Store
+	// Home is opened with g and at start, bookmarks.StartPage shows bookmarks. Empty means HomeURL and help at start
+	Home string
+	// Downloads is where non-text responses are saved after confirmation
+	Downloads string
+	// Cache serves fresh pages without requests and stale ones if capsule is unreachable, nil disables it
+	Cache *pagedb.Cache
+
+	tabs []*tab
+	// tab is the current one of tabs
+	tab *tab
+
+	mode mode
+	// edit is text typed into address bar or prompt
+	edit      []rune
+	inputURL  *url.URL
+	prompt    string
+	sensitive bool
+	mismatch  *gemini.CertMismatchError
+	trustURL  *url.URL
+	// unsaved is non-text response waiting for save confirmation
+	unsaved     *gemini.Response
+	unsavedName string
+
+	message string
+	isError bool
+
+	loadID int
+	cancel context.CancelFunc
+	loaded chan loadResult
+	quit   bool
+}
+
+func New(client *gemini.Client, renderer *render.Renderer, in *bufio.Reader, out io.Writer, width, height int) *App {
+	first := emptyTab()
+	return &App{
+		Client:   client,
+		Renderer: renderer,
+		In:       in,
+		Out:      out,
+		Width:    width,
+		Height:   height,
+		tabs:     []*tab{first},
+		tab:      first,
+		loaded:   make(chan loadResult),
+	}
+}
+
+// Run shows start page, or home page or help if start is nil, and handles keys until q is pressed or input ends
+func (a *App) Run(start *url.URL) error {
+	fmt.Fprint(a.Out, "\033[?1049h")
+	defer fmt.Fprint(a.Out, "\033[?25h\033[?1049l")
+
+	keys := make(chan Key)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			key, err := ReadKey(a.In)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			keys <- key
+		}
+	}()
+
+	resize := make(chan os.Signal, 1)
+	if a.Size != nil {
+		term.NotifyResize(resize)
+		defer signal.Stop(resize)
+	}
+
+	switch {
+	case start != nil:
+		a.open(start, false)
+	case a.Home != "":
+		a.goHome()
+	default:
+		a.showHelp()
+	}
+
+	for !a.quit {
+		a.draw()
+
+		select {
+		case key := <-keys:
+			a.handleKey(key)
+		case result := <-a.loaded:
+			a.finishLoad(result)
+		case <-resize:
+			if width, height, err := a.Size(); err == nil {
+				a.resize(width, height)
+			}
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return nil
+}
+
+func (a *App) resize(width, height int) {
+	a.Width, a.Height = width, height
+	if a.tab.page != nil {
+		a.layout(a.tab.page)
+		a.scrollBy(0)
+	}
+}
+
+// newTab opens tab after the current one and switches to it
+func (a *App) newTab() {
+	t := emptyTab()
+	index := a.tabIndex() + 1
+	a.tabs = append(a.tabs[:index], append([]*tab{t}, a.tabs[index:]...)...)
+	a.switchTab(index)
+}
+
+// closeTab closes current tab, the last one is kept
+func (a *App) closeTab() {
+	if len(a.tabs) == 1 {
+		a.setError(errors.New("cannot close the last tab"))
+		return
+	}
+
+	index := a.tabIndex()
+	a.tabs = append(a.tabs[:index], a.tabs[index+1:]...)
+	a.switchTab(max(index-1, 0))
+}
+
+// switchTab makes tab with index current, cancelling request started in the previous one
+func (a *App) switchTab(index int) {
+	if a.cancel != nil {
+		a.cancel()
+		a.cancel = nil
+		// result of the cancelled request is dropped by finishLoad
+		a.loadID++
+	}
+	a.tab = a.tabs[index]
+	if a.tab.page != nil {
+		// terminal could be resized while tab was in background
+		a.layout(a.tab.page)
+		a.scrollBy(0)
+	}
+	a.setMessage("")
+}
+
+func (a *App) tabIndex() int {
+	for i, t := range a.tabs {
+		if t == a.tab {
+			return i
+		}
+	}
+	return 0
+}
+
+func (a *App) handleKey(key Key) {
+	switch a.mode {
+	case modeAddress, modeInput, modeLinkNumber, modeHistoryNumber:
+		a.handleEditKey(key)
+		return
+
+	case modeTrust:
+		a.mode = modeNormal
+		if key.Code == KeyRune && (key.Rune == 'y' || key.Rune == 'Y') {
+			if err := a.Client.KnownHosts.Trust(a.mismatch.Addr, a.mismatch.Presented); err != nil {
+				a.setError(fmt.Errorf("storing certificate failed: %w", err))
+				return
+			}
+			a.open(a.trustURL, false)
+			return
+		}
+		a.setError(fmt.Errorf("certificate of %s not trusted", a.mismatch.Addr))
+		return
+
+	case modeSave:
+		a.mode = modeNormal
+		resp := a.unsaved
+		a.unsaved = nil
+		if key.Code == KeyRune && (key.Rune == 'y' || key.Rune == 'Y') {
+			path, err := download.Save(a.Downloads, a.unsavedName, bytes.NewReader(resp.Body))
+			if err != nil {
+				a.setError(fmt.Errorf("saving failed: %w", err))
+				return
+			}
+			a.setMessage("saved to " + path)
+			return
+		}
+		a.setMessage("not saved")
+		return
+	}
+
+	switch key.Code {
+	case KeyUp:
+		a.scrollBy(-1)
+	case KeyDown, KeyEnter:
+		if key.Code == KeyEnter && a.tab.selected >= 0 {
+			a.openNumber(a.tab.selected + 1)
+			return
+		}
+		a.scrollBy(1)
+	case KeyPageUp:
+		a.scrollBy(-a.viewHeight())
+	case KeyPageDown:
+		a.scrollBy(a.viewHeight())
+	case KeyHome:
+		a.tab.scroll = 0
+	case KeyEnd:
+		a.scrollBy(len(a.lines()))
+	case KeyTab:
+		a.selectLink(1)
+	case KeyBacktab:
+		a.selectLink(-1)
+	case KeyEscape, KeyCtrlC:
+		if a.cancel != nil {
+			a.cancel()
+		} else if key.Code == KeyCtrlC {
+			a.setMessage("press q to quit")
+		}
+	case KeyRune:
+		a.handleRune(key.Rune)
+	}
+}
+
+func (a *App) handleRune(r rune) {
+	switch {
+	case r == 'q':
+		a.quit = true
+	case r == 'h':
+		a.showHelp()
+	case r == 'g':
+		a.goHome()
+	case r == 'B':
+		a.showBookmarks()
+	case r == 'a':
+		a.addBookmark()
+	case r == 'd':
+		a.deleteBookmark()
+	case r == 'b':
+		a.back()
+	case r == 'f':
+		a.forward()
+	case r == 'H':
+		a.mode = modeHistoryNumber
+		a.edit = nil
+	case r == 'l':
+		a.showLinks()
+	case r == 'i':
+		a.showInfo()
+	case r == 'r':
+		if a.tab.page != nil && a.tab.page.url != nil {
+			a.open(a.tab.page.url, true)
+		}
+	case r == 't':
+		selected := a.tab.selected
+		previous := a.tab
+		a.newTab()
+		if selected >= 0 {
+			a.openNumberFrom(previous.page, selected+1)
+		} else {
+			a.showHelp()
+		}
+	case r == 'w':
+		a.closeTab()
+	case r == '[':
+		a.switchTab((a.tabIndex() - 1 + len(a.tabs)) % len(a.tabs))
+	case r == ']':
+		a.switchTab((a.tabIndex() + 1) % len(a.tabs))
+	case r == 'o':
+		a.mode = modeAddress
+		a.edit = nil
+		if a.tab.page != nil && a.tab.page.url != nil {
+			a.edit = []rune(a.tab.page.url.String())
+		}
+	case r == 'j':
+		a.scrollBy(1)
+	case r == 'k':
+		a.scrollBy(-1)
+	case r == ' ':
+		a.scrollBy(a.viewHeight())
+	case r >= '0' && r <= '9':
+		a.mode = modeLinkNumber
+		a.edit = []rune{r}
+	}
+}
+
+// handleEditKey edits address bar or prompt text, Enter submits it and Escape cancels
+func (a *App) handleEditKey(key Key) {
+	switch key.Code {
+	case KeyRune:
+		a.edit = append(a.edit, key.Rune)
+		return
+	case KeyBackspace:
+		if len(a.edit) > 0 {
+			a.edit = a.edit[:len(a.edit)-1]
+		}
+		return
+	case KeyCtrlU:
+		a.edit = nil
+		return
+	case KeyEscape, KeyCtrlC:
+		if a.mode == modeInput {
+			a.setError(errors.New("input cancelled"))
+		}
+		a.mode = modeNormal
+		return
+	case KeyEnter:
+	default:
+		return
+	}
+
+	text := string(a.edit)
+	submitted := a.mode
+	a.mode = modeNormal
+
+	switch submitted {
+	case modeAddress:
+		link, err := gemini.GetFullGeminiLink(text)
+		if err != nil {
+			a.setError(err)
+			return
+		}
+		a.open(link, false)
+
+	case modeInput:
+		if text == "" {
+			a.setError(errors.New("input cancelled"))
+			return
+		}
+		link, err := gemini.WithQuery(a.inputURL, text)
+		if err != nil {
+			a.setError(err)
+			return
+		}
+		a.open(link, false)
+
+	case modeLinkNumber:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			a.setError(errors.New("not a link number"))
+			return
+		}
+		a.openNumber(number)
+
+	case modeHistoryNumber:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			a.setError(errors.New("not a history entry number"))
+			return
+		}
+		// entries are numbered from 1 like on the links page
+		a.travel(number-1-a.tab.history.Current(), "no such history entry")
+	}
+}
+
+// open requests link in background, cancelling request in progress
+func (a *App) open(link *url.URL, reload bool) {
+	if a.cancel != nil {
+		a.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.loadID++
+	id := a.loadID
+	a.setMessage("loading " + link.String())
+
+	go func() {
+		start := time.Now()
+		resp, cached, err := a.fetch(ctx, link, reload)
+		a.loaded <- loadResult{id: id, link: link, resp: resp, err: err, elapsed: time.Since(start), reload: reload, cached: cached}
+	}()
+}
+
+// fetch requests link through the cache, reload skips fresh cached pages unless cache is offline.
+// cached describes where cached response came from, empty for responses of capsule
+func (a *App) fetch(ctx context.Context, link *url.URL, reload bool) (resp *gemini.Response, cached string, err error) {
+	resp, source, err := a.Cache.Fetch(ctx, a.Client, link, reload)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.BodyReader != nil {
+		// non-text bodies are kept in memory until saving is confirmed
+		resp.Body, err = io.ReadAll(resp.BodyReader)
+		resp.BodyReader.Close()
+		resp.BodyReader = nil
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	switch {
+	case source == nil:
+		return resp, "", nil
+	case source.Stale:
+		return resp, "\033[33mstale copy from " + source.Fetched.Local().Format(time.DateTime) + "\033[0m", nil
+	default:
+		return resp, "cached", nil
+	}
+}
+
+func (a *App) finishLoad(result loadResult) {
+	if result.id != a.loadID {
+		// cancelled request replaced by a newer one
+		return
+	}
+	a.cancel()
+	a.cancel = nil
+
+	if result.err != nil {
+		var mismatch *gemini.CertMismatchError
+		switch {
+		case errors.As(result.err, &mismatch) && a.Client.KnownHosts != nil:
+			a.mode = modeTrust
+			a.mismatch = mismatch
+			a.trustURL = result.link
+		case errors.Is(result.err, context.Canceled):
+			a.setError(errors.New("request cancelled"))
+		default:
+			a.setError(fmt.Errorf("request failed: %w", result.err))
+		}
+		return
+	}
+
+	resp := result.resp
+	link := result.link
+	if resp.URL != nil {
+		link = resp.URL
+	}
+
+	switch resp.Status {
+	case gemini.StatusInput:
+		a.mode = modeInput
+		a.edit = nil
+		a.prompt = resp.Meta
+		a.sensitive = resp.Code == gemini.CodeSensitiveInput
+		a.inputURL = link
+
+	case gemini.StatusSuccess:
+		if mediaType, err := resp.MediaType(); err == nil && !mediaType.IsText() {
+			a.mode = modeSave
+			a.unsaved = resp
+			a.unsavedName = download.FileName(link, mediaType)
+			return
+		}
+
+		p, err := a.newPage(link, resp)
+		if err != nil {
+			a.setError(err)
+			return
+		}
+		p.elapsed = result.elapsed
+		p.cached = result.cached
+		a.show(p, !result.reload)
+		if a.History != nil {
+			if err := a.History.Add(link.String(), p.doc.Title(), time.Now()); err != nil {
+				a.setError(fmt.Errorf("storing history failed: %w", err))
+			}
+		}
+
+	case gemini.StatusClientCertRequired:
+		a.setError(fmt.Errorf("%w (manage certificates with id commands in line mode)", resp.Err()))
+
+	default:
+		if err := resp.Err(); err != nil {
+			a.setError(err)
+		} else {
+			a.setError(fmt.Errorf("unexpected response: %d %s", resp.Code, resp.Meta))
+		}
+	}
+}
+
+func (a *App) newPage(link *url.URL, resp *gemini.Response) (*page, error) {
+	mediaType, err := resp.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if !mediaType.IsText() {
+		return nil, fmt.Errorf("unsupported type: %s", resp.Meta)
+	}
+
+	_, body, err := gemini.DecodeText(mediaType, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{
+		url:    link,
+		title:  link.String(),
+		status: fmt.Sprintf("%d %s", resp.Code, resp.Meta),
+		size:   len(resp.Body),
+		resp:   resp,
+	}
+	if mediaType.IsGemtext() {
+		p.doc = gemtext.ParseString(string(body))
+	} else {
+		p.text = strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	}
+	a.layout(p)
+	return p, nil
+}
+
+// internalPage shows gemtext generated by the browser itself
+func (a *App) internalPage(title, body string) *page {
+	p := &page{title: title, doc: gemtext.ParseString(body)}
+	a.layout(p)
+	return p
+}
+
+func (a *App) layout(p *page) {
+	if p.doc == nil {
+		p.lines = p.text
+		return
+	}
+
+	a.Renderer.Width = a.Width
+	p.lines, p.linkLines = a.Renderer.Layout(p.doc, p.url)
+	p.links = p.links[:0]
+	for _, link := range p.doc.Links() {
+		resolved, err := link.Resolve(p.url)
+		if err != nil {
+			resolved = nil
+		}
+		p.links = append(p.links, resolved)
+	}
+}
+
+// show replaces current page, adding it to history if push is set
+func (a *App) show(p *page, push bool) {
+	t := a.tab
+	t.leave()
+	key := historyKey(p)
+	if push || t.history.Current() < 0 {
+		t.history.Visit(key)
+		t.forget()
+	}
+	t.pages[key] = visit{page: p}
+
+	t.page = p
+	t.scroll = 0
+	t.selected = -1
+	a.setMessage("")
+}
+
+func (a *App) back() {
+	a.travel(-1, "no history yet")
+}
+
+func (a *App) forward() {
+	a.travel(1, "nothing to go forward to")
+}
+
+// travel shows history entry offset from the current one, pages are not requested again.
+// missing is the error shown if there is no such entry
+func (a *App) travel(offset int, missing string) {
+	t := a.tab
+	entry, ok := t.history.At(offset)
+	target, loaded := t.pages[entry]
+	if !ok || offset == 0 || !loaded {
+		a.setError(errors.New(missing))
+		return
+	}
+
+	t.leave()
+	t.history.Move(offset)
+	a.layout(target.page)
+	t.page = target.page
+	t.scroll = target.scroll
+	t.selected = -1
+	a.scrollBy(0)
+	a.setMessage("")
+}
+
+// leave remembers scroll position of the current page for travelling back to it
+func (t *tab) leave() {
+	if t.page != nil {
+		t.pages[historyKey(t.page)] = visit{page: t.page, scroll: t.scroll}
+	}
+}
+
+// forget drops pages of entries which are not in history anymore
+func (t *tab) forget() {
+	kept := make(map[string]visit, len(t.pages))
+	for _, entry := range t.history.Entries() {
+		if v, ok := t.pages[entry]; ok {
+			kept[entry] = v
+		}
+	}
+	t.pages = kept
+}
+
+func (a *App) goHome() {
+	if a.Home == bookmarks.StartPage {
+		a.showBookmarks()
+		return
+	}
+
+	home := HomeURL
+	if a.Home != "" {
+		home = a.Home
+	}
+	link, err := gemini.GetFullGeminiLink(home)
+	if err != nil {
+		a.setError(err)
+		return
+	}
+	a.open(link, false)
+}
+
+const bookmarksTitle = "bookmarks"
+
+func (a *App) showBookmarks() {
+	if a.Bookmarks == nil {
+		a.setError(errors.New("bookmarks are not available"))
+		return
+	}
+	a.show(a.internalPage(bookmarksTitle, a.Bookmarks.Page("").String()), true)
+}
+
+func (a *App) addBookmark() {
+	p := a.tab.page
+	if a.Bookmarks == nil || p == nil || p.url == nil {
+		a.setError(errors.New("nothing to bookmark"))
+		return
+	}
+	if err := a.Bookmarks.Add(p.url.String(), p.doc.Title(), nil, time.Now()); err != nil {
+		a.setError(err)
+		return
+	}
+	a.setMessage("bookmarked " + p.url.String())
+}
+
+// deleteBookmark removes selected link of bookmarks page, links there are numbered as bookmarks
+func (a *App) deleteBookmark() {
+	p := a.tab.page
+	if a.Bookmarks == nil || p == nil || p.url != nil || p.title != bookmarksTitle || a.tab.selected < 0 {
+		a.setError(errors.New("select a link on bookmarks page to delete it"))
+		return
+	}
+
+	// bookmarks page of the TUI always lists all of them
+	if err := a.Bookmarks.Delete("", a.tab.selected+1); err != nil {
+		a.setError(err)
+		return
+	}
+	a.show(a.internalPage(bookmarksTitle, a.Bookmarks.Page("").String()), false)
+	a.setMessage("bookmark deleted")
+}
+
+func (a *App) showHelp() {
+	a.show(a.internalPage("help", helpPage), true)
+}
+
+// showInfo describes response of the current page
+func (a *App) showInfo() {
+	p := a.tab.page
+	if p == nil || p.resp == nil {
+		a.setError(errors.New("no page opened yet"))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("# Page info\n```\n")
+	for _, line := range p.resp.Describe() {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("```\n")
+	a.show(a.internalPage("info", b.String()), true)
+}
+
+func (a *App) showLinks() {
+	var b strings.Builder
+	b.WriteString("# Links\n")
+	if a.tab.page != nil {
+		for _, link := range a.tab.page.links {
+			if link != nil {
+				b.WriteString("=> " + link.String() + "\n")
+			}
+		}
+	}
+
+	// entries are listed like hist of the line mode client, H and number goes to one
+	b.WriteString("\n# History\n")
+	for i, entry := range a.tab.history.Entries() {
+		marker := " "
+		if i == a.tab.history.Current() {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "%d%s %s\n", i+1, marker, entry)
+	}
+	a.show(a.internalPage("links", b.String()), true)
+}
+
+func (a *App) openNumber(number int) {
+	a.openNumberFrom(a.tab.page, number)
+}
+
+// openNumberFrom opens link with number from p in the current tab
+func (a *App) openNumberFrom(p *page, number int) {
+	if p == nil || number < 1 || number > len(p.links) {
+		a.setError(errors.New("no link with this number"))
+		return
+	}
+
+	link := p.links[number-1]
+	if link == nil {
+		a.setError(errors.New("malformed link"))
+		return
+	}
+	if link.Scheme != "gemini" && link.Scheme != "" {
+		a.setError(fmt.Errorf("cannot open %s links: %s", link.Scheme, link))
+		return
+	}
+
+	full, err := gemini.GetFullGeminiLink(link.String())
+	if err != nil {
+		a.setError(err)
+		return
+	}
+	a.open(full, false)
+}
+
+func (a *App) lines() []string {
+	if a.tab.page == nil {
+		return nil
+	}
+	return a.tab.page.lines
+}
+
+// viewHeight is number of page rows between address bar and status line
+func (a *App) viewHeight() int {
+	return max(a.Height-2, 1)
+}
+
+func (a *App) scrollBy(delta int) {
+	maxScroll := max(len(a.lines())-a.viewHeight(), 0)
+	a.tab.scroll = min(max(a.tab.scroll+delta, 0), maxScroll)
+}
+
+// selectLink moves selection by delta links, starting from the first visible one
+func (a *App) selectLink(delta int) {
+	p := a.tab.page
+	if p == nil || len(p.linkLines) == 0 {
+		return
+	}
+
+	count := len(p.linkLines)
+	if a.tab.selected < 0 {
+		a.tab.selected = 0
+		if delta < 0 {
+			a.tab.selected = count - 1
+		}
+		for i, line := range p.linkLines {
+			visible := line >= a.tab.scroll && line < a.tab.scroll+a.viewHeight()
+			if visible {
+				a.tab.selected = i
+				if delta > 0 {
+					break
+				}
+			}
+		}
+	} else {
+		a.tab.selected = (a.tab.selected + delta + count) % count
+	}
+
+	line := p.linkLines[a.tab.selected]
+	if line < a.tab.scroll {
+		a.tab.scroll = line
+	} else if line >= a.tab.scroll+a.viewHeight() {
+		a.tab.scroll = line - a.viewHeight() + 1
+	}
+}
+
+func (a *App) setMessage(message string) {
+	a.message = message
+	a.isError = false
+}
+
+func (a *App) setError(err error) {
+	a.message = err.Error()
+	a.isError = true
+}
+
+// draw redraws the whole screen: address bar, page and status line
+func (a *App) draw() {
+	var b strings.Builder
+	b.WriteString("\033[?25l\033[H")
+
+	address := ""
+	if a.tab.page != nil {
+		address = a.tab.page.title
+	}
+	if len(a.tabs) > 1 && a.mode != modeAddress {
+		address = fmt.Sprintf("[%d/%d] %s", a.tabIndex()+1, len(a.tabs), address)
+	}
+	if a.mode == modeAddress {
+		address = string(a.edit)
+	}
+	b.WriteString("\033[7m" + pad(render.Truncate(" "+address, a.Width), a.Width) + "\033[0m\r\n")
+
+	lines := a.lines()
+	for i := 0; i < a.viewHeight(); i++ {
+		row := ""
+		if index := a.tab.scroll + i; index < len(lines) {
+			row = lines[index]
+			if a.tab.selected >= 0 && index == a.tab.page.linkLines[a.tab.selected] {
+				row = "\033[7m" + render.StripANSI(row) + "\033[0m"
+			}
+		}
+		b.WriteString(render.Truncate(row, a.Width) + "\033[K\r\n")
+	}
+
+	status, editing := a.statusLine()
+	b.WriteString(render.Truncate(status, a.Width) + "\033[K")
+
+	if editing {
+		// show cursor after edited text
+		if a.mode == modeAddress {
+			fmt.Fprintf(&b, "\033[1;%dH", min(render.StringWidth(address)+2, a.Width))
+		} else {
+			fmt.Fprintf(&b, "\033[%d;%dH", a.Height, min(render.StringWidth(status)+1, a.Width))
+		}
+		b.WriteString("\033[?25h")
+	}
+
+	_, _ = io.WriteString(a.Out, b.String())
+}
+
+// statusLine returns bottom line and whether it is being edited
+func (a *App) statusLine() (string, bool) {
+	switch a.mode {
+	case modeAddress:
+		return "Enter: open, Esc: cancel", true
+	case modeInput:
+		text := string(a.edit)
+		if a.sensitive {
+			text = strings.Repeat("*", len(a.edit))
+		}
+		return a.prompt + ": " + text, true
+	case modeLinkNumber:
+		return "Open link: " + string(a.edit), false
+	case modeHistoryNumber:
+		return "Go to history entry: " + string(a.edit), false
+	case modeSave:
+		return fmt.Sprintf("\033[33m%s is %s, %s. Save to %s? [y/N]\033[0m",
+			a.unsavedName, a.unsaved.Meta, formatSize(len(a.unsaved.Body)), a.Downloads), false
+	case modeTrust:
+		return fmt.Sprintf("\033[31mCertificate of %s changed! known %.16s, presented %.16s. Trust it? [y/N]\033[0m",
+			a.mismatch.Addr, a.mismatch.Known.Fingerprint, a.mismatch.Presented.Fingerprint), false
+	}
+
+	if a.message != "" {
+		if a.isError {
+			return "\033[31m" + a.message + "\033[0m", false
+		}
+		return a.message, false
+	}
+
+	p := a.tab.page
+	if p == nil {
+		return "h: help", false
+	}
+
+	parts := []string{}
+	if p.status != "" {
+		parts = append(parts, p.status, formatSize(p.size), p.elapsed.Round(time.Millisecond).String())
+	}
+	if p.cached != "" {
+		parts = append(parts, p.cached)
+	}
+	if len(p.lines) > 0 {
+		bottom := min(a.tab.scroll+a.viewHeight(), len(p.lines))
+		parts = append(parts, fmt.Sprintf("%d%%", bottom*100/len(p.lines)))
+	}
+	if a.tab.selected >= 0 {
+		parts = append(parts, fmt.Sprintf("link %d/%d", a.tab.selected+1, len(p.links)))
+	}
+	parts = append(parts, "h: help")
+	return strings.Join(parts, " | "), false
+}
+
+func formatSize(size int) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/1024)
+}
+
+// pad appends spaces up to width columns
+func pad(s string, width int) string {
+	if w := render.StringWidth(render.StripANSI(s)); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanthekat/gemini-tools/internal/bookmarks"
+	"github.com/romanthekat/gemini-tools/internal/gemini"
+	"github.com/romanthekat/gemini-tools/internal/pagedb"
+	"github.com/romanthekat/gemini-tools/internal/render"
+)
+
+func newTestApp(input string, height int) (*App, *bytes.Buffer) {
+	var out bytes.Buffer
+	app := New(gemini.NewClient(), render.New(0), bufio.NewReader(strings.NewReader(input)), &out, 40, height)
+	return app, &out
+}
+
+// linksPage has text lines with a link after every tenth one
+func linksPage(lines int) string {
+	var b strings.Builder
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+		if i%10 == 0 {
+			fmt.Fprintf(&b, "=> /%d link %d\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func TestAppRunQuit(t *testing.T) {
+	app, out := newTestApp("q", 10)
+	if err := app.Run(nil); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got := out.String()
+	if !strings.HasPrefix(got, "\033[?1049h") || !strings.HasSuffix(got, "\033[?1049l") {
+		t.Errorf("alternate screen is not entered and left: %q", got)
+	}
+	if !strings.Contains(got, "q: quit") {
+		t.Errorf("help is not shown at start")
+	}
+}
+
+func TestAppScroll(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("test", linksPage(20)), true)
+
+	keys := []Key{{Code: KeyPageDown}, {Code: KeyRune, Rune: 'j'}, {Code: KeyUp}}
+	for _, key := range keys {
+		app.handleKey(key)
+	}
+	if app.tab.scroll != 8 {
+		t.Errorf("unexpected scroll after paging: %d", app.tab.scroll)
+	}
+
+	app.handleKey(Key{Code: KeyEnd})
+	if want := len(app.lines()) - app.viewHeight(); app.tab.scroll != want {
+		t.Errorf("End should scroll to the bottom: %d, want %d", app.tab.scroll, want)
+	}
+	app.handleKey(Key{Code: KeyHome})
+	if app.tab.scroll != 0 {
+		t.Errorf("Home should scroll to the top: %d", app.tab.scroll)
+	}
+}
+
+func TestAppSelectLink(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("test", linksPage(30)), true)
+
+	// first link is on line 10, below the 8 visible rows
+	app.handleKey(Key{Code: KeyTab})
+	if app.tab.selected != 0 || app.tab.scroll != 3 {
+		t.Fatalf("first link should be selected and scrolled into view: %d, %d", app.tab.selected, app.tab.scroll)
+	}
+
+	app.handleKey(Key{Code: KeyBacktab})
+	app.handleKey(Key{Code: KeyBacktab})
+	if app.tab.selected != 1 {
+		t.Errorf("selection should wrap around: %d", app.tab.selected)
+	}
+}
+
+func TestAppBack(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("first", linksPage(20)), true)
+	app.scrollBy(5)
+	app.handleKey(Key{Code: KeyRune, Rune: 'h'})
+
+	app.handleKey(Key{Code: KeyRune, Rune: 'b'})
+	if app.tab.page.title != "first" || app.tab.scroll != 5 {
+		t.Fatalf("back should restore previous page and scroll: %s, %d", app.tab.page.title, app.tab.scroll)
+	}
+
+	app.handleKey(Key{Code: KeyRune, Rune: 'b'})
+	if !app.isError || app.message != "no history yet" {
+		t.Errorf("unexpected message: %q", app.message)
+	}
+
+	app.handleKey(Key{Code: KeyRune, Rune: 'f'})
+	if app.tab.page.title != "help" || app.tab.history.Current() != 1 {
+		t.Fatalf("forward should return to help: %s", app.tab.page.title)
+	}
+
+	// opening a page drops forward history
+	app.handleKey(Key{Code: KeyRune, Rune: 'b'})
+	app.handleKey(Key{Code: KeyRune, Rune: 'l'})
+	app.handleKey(Key{Code: KeyRune, Rune: 'f'})
+	if app.tab.page.title != "links" || app.message != "nothing to go forward to" {
+		t.Errorf("unexpected page after forward: %s, %q", app.tab.page.title, app.message)
+	}
+	if entries := app.tab.history.Entries(); len(entries) != 2 || entries[1] != "about:links" {
+		t.Errorf("unexpected history: %v", entries)
+	}
+}
+
+func TestAppHistoryJump(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("first", linksPage(20)), true)
+	app.scrollBy(5)
+	app.show(app.internalPage("second", "text"), true)
+	app.show(app.internalPage("third", "text"), true)
+
+	for _, key := range []Key{{Code: KeyRune, Rune: 'H'}, {Code: KeyRune, Rune: '1'}, {Code: KeyEnter}} {
+		app.handleKey(key)
+	}
+	if app.tab.page.title != "first" || app.tab.scroll != 5 || app.tab.history.Current() != 0 {
+		t.Fatalf("should jump to the first entry: %s, %d", app.tab.page.title, app.tab.scroll)
+	}
+
+	// jumping keeps entries ahead
+	app.handleKey(Key{Code: KeyRune, Rune: 'f'})
+	if app.tab.page.title != "second" {
+		t.Fatalf("forward after jump should show second page: %s", app.tab.page.title)
+	}
+
+	for _, key := range []Key{{Code: KeyRune, Rune: 'H'}, {Code: KeyRune, Rune: '7'}, {Code: KeyEnter}} {
+		app.handleKey(key)
+	}
+	if !app.isError || app.message != "no such history entry" || app.tab.page.title != "second" {
+		t.Errorf("unexpected jump to missing entry: %s, %q", app.tab.page.title, app.message)
+	}
+
+	app.showLinks()
+	if !strings.Contains(strings.Join(app.tab.page.lines, "\n"), "2* about:second") {
+		t.Errorf("links page should list history with current entry: %v", app.tab.page.lines)
+	}
+}
+
+func TestAppLinkNumberErrors(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("test", "=> https://example.org web\n"), true)
+
+	for _, key := range []Key{{Code: KeyRune, Rune: '1'}, {Code: KeyEnter}} {
+		app.handleKey(key)
+	}
+	if !app.isError || !strings.Contains(app.message, "cannot open https links") {
+		t.Errorf("unexpected message: %q", app.message)
+	}
+
+	for _, key := range []Key{{Code: KeyRune, Rune: '5'}, {Code: KeyEnter}} {
+		app.handleKey(key)
+	}
+	if app.message != "no link with this number" {
+		t.Errorf("unexpected message: %q", app.message)
+	}
+}
+
+func TestAppTabs(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.show(app.internalPage("first", linksPage(20)), true)
+
+	app.handleKey(Key{Code: KeyRune, Rune: 't'})
+	if len(app.tabs) != 2 || app.tab.page.title != "help" {
+		t.Fatalf("new tab should show help: %d tabs, %s", len(app.tabs), app.tab.page.title)
+	}
+	if entries := app.tab.history.Entries(); len(entries) != 1 || entries[0] != "about:help" {
+		t.Errorf("new tab should have its own history: %v", entries)
+	}
+
+	app.handleKey(Key{Code: KeyRune, Rune: ']'})
+	if app.tab.page.title != "first" {
+		t.Errorf("next tab should wrap to the first one: %s", app.tab.page.title)
+	}
+
+	app.handleKey(Key{Code: KeyRune, Rune: 'w'})
+	app.handleKey(Key{Code: KeyRune, Rune: 'w'})
+	if len(app.tabs) != 1 || app.tab.page.title != "help" || app.message != "cannot close the last tab" {
+		t.Errorf("unexpected tabs after closing: %d, %s, %q", len(app.tabs), app.tab.page.title, app.message)
+	}
+}
+
+func TestAppBookmarks(t *testing.T) {
+	store, err := bookmarks.NewStore(filepath.Join(t.TempDir(), "bookmarks"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	_ = store.Add("gemini://example.org/", "Example", nil, time.Now())
+	_ = store.Add("gemini://other.org/", "Other", nil, time.Now())
+
+	app, _ := newTestApp("", 10)
+	app.Bookmarks = store
+	app.Home = bookmarks.StartPage
+	app.handleKey(Key{Code: KeyRune, Rune: 'g'})
+	if app.tab.page.title != "bookmarks" || len(app.tab.page.links) != 2 {
+		t.Fatalf("home should show bookmarks: %s, %v", app.tab.page.title, app.tab.page.links)
+	}
+
+	app.handleKey(Key{Code: KeyTab})
+	app.handleKey(Key{Code: KeyRune, Rune: 'd'})
+	if got := store.List(""); len(got) != 1 || got[0].Title != "Other" {
+		t.Fatalf("selected bookmark should be deleted: %+v", got)
+	}
+	if len(app.tab.page.links) != 1 {
+		t.Errorf("bookmarks page should be refreshed: %v", app.tab.page.links)
+	}
+
+	app.handleKey(Key{Code: KeyRune, Rune: 'a'})
+	if app.message != "nothing to bookmark" {
+		t.Errorf("internal pages should not be bookmarked: %q", app.message)
+	}
+}
+
+func TestAppSaveBinary(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.Downloads = t.TempDir()
+	link, _ := url.Parse("gemini://example.org/cat.png")
+
+	finish := func() {
+		app.loadID++
+		app.cancel = func() {}
+		resp := &gemini.Response{Status: gemini.StatusSuccess, Code: gemini.CodeSuccess, Meta: "image/png", Body: []byte("png")}
+		app.finishLoad(loadResult{id: app.loadID, link: link, resp: resp})
+	}
+
+	finish()
+	if status, _ := app.statusLine(); app.mode != modeSave || !strings.Contains(status, "cat.png is image/png") {
+		t.Fatalf("save should be offered: %q", status)
+	}
+	app.handleKey(Key{Code: KeyRune, Rune: 'n'})
+	if app.message != "not saved" {
+		t.Errorf("unexpected message: %q", app.message)
+	}
+
+	finish()
+	app.handleKey(Key{Code: KeyRune, Rune: 'y'})
+	if body, err := os.ReadFile(filepath.Join(app.Downloads, "cat.png")); err != nil || string(body) != "png" {
+		t.Errorf("unexpected saved file: %q %v", body, err)
+	}
+}
+
+func TestAppFetchCache(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.Cache = pagedb.NewCache(t.TempDir(), time.Hour)
+
+	// nothing listens on port 1, so requests fail right away
+	link, _ := url.Parse("gemini://127.0.0.1:1/")
+	if err := app.Cache.Put(link, gemini.NewResponseCode(gemini.CodeSuccess, gemini.GeminiMediaType, []byte("# Cached\n"))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	resp, cached, err := app.fetch(context.Background(), link, false)
+	if err != nil || cached != "cached" || string(resp.Body) != "# Cached\n" {
+		t.Fatalf("fresh page should come from cache: %q %v", cached, err)
+	}
+
+	resp, cached, err = app.fetch(context.Background(), link, true)
+	if err != nil || !strings.Contains(cached, "stale") || string(resp.Body) != "# Cached\n" {
+		t.Fatalf("stale page should be shown on reload of unreachable capsule: %q %v", cached, err)
+	}
+
+	missing, _ := url.Parse("gemini://127.0.0.1:1/missing")
+	if _, _, err := app.fetch(context.Background(), missing, false); err == nil {
+		t.Fatalf("expected request error for page not in cache")
+	}
+}
+
+func TestAppFetchOffline(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	dir := t.TempDir()
+	app.Cache = &pagedb.Cache{DB: pagedb.New(dir), TTL: pagedb.Forever, Offline: true, Queue: filepath.Join(dir, "queue.txt")}
+
+	link, _ := url.Parse("gemini://127.0.0.1:1/")
+	if err := app.Cache.Put(link, gemini.NewResponseCode(gemini.CodeSuccess, gemini.GeminiMediaType, []byte("# Local\n"))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if resp, _, err := app.fetch(context.Background(), link, true); err != nil || string(resp.Body) != "# Local\n" {
+		t.Fatalf("page should be read from DB even on reload: %v", err)
+	}
+
+	missing, _ := url.Parse("gemini://127.0.0.1:1/missing")
+	if _, _, err := app.fetch(context.Background(), missing, false); err == nil || !strings.Contains(err.Error(), "queued") {
+		t.Fatalf("missing page should be queued: %v", err)
+	}
+	if queue, _ := os.ReadFile(app.Cache.Queue); string(queue) != "gemini://127.0.0.1:1/missing\n" {
+		t.Fatalf("unexpected queue: %q", queue)
+	}
+}
+
+func TestAppShowInfo(t *testing.T) {
+	app, _ := newTestApp("", 10)
+	app.showInfo()
+	if !app.isError {
+		t.Fatalf("expected error without opened page")
+	}
+
+	link, _ := url.Parse("gemini://example.org/")
+	resp := &gemini.Response{Status: gemini.StatusSuccess, Code: gemini.CodeSuccess, Meta: "text/gemini", Body: []byte("# Hi\n"), URL: link}
+	app.loadID++
+	app.cancel = func() {}
+	app.finishLoad(loadResult{id: app.loadID, link: link, resp: resp})
+
+	app.showInfo()
+	info := strings.Join(app.tab.page.lines, "\n")
+	if app.tab.page.title != "info" || !strings.Contains(info, "status: 20 SUCCESS") {
+		t.Fatalf("unexpected info page: %q", info)
+	}
+}